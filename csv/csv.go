@@ -1,5 +1,417 @@
+/*
+This is a package that is designed to convert a csv reader into a record, and write down the record into a file of known format.  It uses encoding/csv to do a lot of the heavy lifting, and goflect.GetInfo to determine the layout of the file
+
+The header row is mapped to fields by the ui-name tag, the sql-column tag, or the field name, in that order.  Empty cells use the default tag, and every row is checked against the valid tag of each field before it is handed back.  The writer emits fields in the order of the order tag, skipping hidden fields and masking redacted ones
+*/
 package goflect
 
-/**
-TODO - This is a package that is designed to convert a csv reader into a record, and write down the record into a file of known format.  It uses encoding/csv to do a lot of the heavy lifting
-**/
+import (
+	"encoding/csv"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+This is what is written in place of a field marked ui:"redacted"
+*/
+const REDACTED = "********"
+
+/*
+This is the error returned when a cell cannot be read into a record.  Row and Column are 1 based, and the header is row 1, so they line up with what a spreadsheet shows.  Column is 0 for a field that has no column in the file
+*/
+type CsvError struct {
+	Row     int
+	Column  int
+	Field   string
+	Message string
+}
+
+func (e CsvError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("row %v (%v): %v", e.Row, e.Field, e.Message)
+	}
+	return fmt.Sprintf("row %v, column %v (%v): %v", e.Row, e.Column, e.Field, e.Message)
+}
+
+/*
+This reads records out of a csv stream, one struct at a time
+*/
+type Reader struct {
+	reader   *csv.Reader
+	typ      reflect.Type
+	columns  []goflect.Info
+	indexes  []int //This is the position of each column in a row
+	missing  []goflect.Info
+	matchers map[string]matcher.Matcher
+	row      int
+}
+
+/*
+This is the name used in the header of a field
+*/
+func headerName(field goflect.Info) string {
+	if field.DisplayName != "" {
+		return field.DisplayName
+	}
+	return field.Name
+}
+
+func recordType(record interface{}) reflect.Type {
+	typ := reflect.TypeOf(record)
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+/*
+This creates a reader for the type of the provided record.  It consumes the header row immediately, so that unknown columns are reported before any records are read.  Columns with an empty header are skipped
+*/
+func NewReader(r io.Reader, record interface{}) (*Reader, error) {
+	output := &Reader{reader: csv.NewReader(r), typ: recordType(record), matchers: make(map[string]matcher.Matcher)}
+	prototype := reflect.New(output.typ).Interface()
+	fields := goflect.GetInfo(prototype)
+
+	header, err := output.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	output.row = 1
+
+	used := make(map[string]bool)
+	for i, name := range header {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		found := false
+		for _, field := range fields {
+			if name == field.DisplayName || name == field.SqlColumn || name == field.Name {
+				output.columns = append(output.columns, field)
+				output.indexes = append(output.indexes, i)
+				used[field.Name] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, CsvError{Row: 1, Column: i + 1, Field: name, Message: "Unknown column"}
+		}
+	}
+	for _, field := range fields {
+		if !used[field.Name] {
+			output.missing = append(output.missing, field)
+		}
+		if field.ValidExpr == "" {
+			continue
+		}
+		match, err := goflect.Parse(prototype, field.ValidExpr)
+		if err != nil {
+			return nil, err
+		}
+		output.matchers[field.Name] = match
+	}
+	return output, nil
+}
+
+/*
+This reads the next row into the record, which must be a pointer.  It will return io.EOF when there are no more rows
+*/
+func (r *Reader) Read(record interface{}) error {
+	val := reflect.ValueOf(record)
+	if val.Kind() != reflect.Ptr || val.Elem().Type() != r.typ {
+		return CsvError{Row: r.row, Message: "Read requires a pointer to " + r.typ.Name()}
+	}
+	val = val.Elem()
+
+	cells, err := r.reader.Read()
+	if err != nil {
+		return err
+	}
+	r.row++
+
+	for i, field := range r.columns {
+		cell := cells[r.indexes[i]]
+		if cell == "" {
+			cell = field.Default
+		}
+		err = setField(val.FieldByName(field.Name), field, cell)
+		if err != nil {
+			return CsvError{Row: r.row, Column: r.indexes[i] + 1, Field: field.Name, Message: err.Error()}
+		}
+	}
+	for _, field := range r.missing {
+		err = setField(val.FieldByName(field.Name), field, field.Default)
+		if err != nil {
+			return CsvError{Row: r.row, Field: field.Name, Message: err.Error()}
+		}
+	}
+
+	return r.validate(record)
+}
+
+func (r *Reader) validate(record interface{}) error {
+	for i, field := range r.columns {
+		if err := r.validateField(record, field, r.indexes[i]+1); err != nil {
+			return err
+		}
+	}
+	for _, field := range r.missing {
+		if err := r.validateField(record, field, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) validateField(record interface{}, field goflect.Info, column int) error {
+	match, present := r.matchers[field.Name]
+	if !present {
+		return nil
+	}
+	result, err := match.Match(record)
+	if err != nil {
+		return CsvError{Row: r.row, Column: column, Field: field.Name, Message: err.Error()}
+	}
+	if !result {
+		return CsvError{Row: r.row, Column: column, Field: field.Name, Message: "Failed validation: " + field.ValidExpr}
+	}
+	return nil
+}
+
+/*
+This reads every remaining row, and appends them to the slice pointed to by records
+*/
+func (r *Reader) ReadAll(records interface{}) error {
+	slice := reflect.ValueOf(records)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return CsvError{Row: r.row, Message: "ReadAll requires a pointer to a slice"}
+	}
+	slice = slice.Elem()
+	elemIsPtr := slice.Type().Elem().Kind() == reflect.Ptr
+	for {
+		record := reflect.New(r.typ)
+		err := r.Read(record.Interface())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if elemIsPtr {
+			slice.Set(reflect.Append(slice, record))
+		} else {
+			slice.Set(reflect.Append(slice, record.Elem()))
+		}
+	}
+}
+
+/*
+This is a convenience function that reads an entire csv stream into the slice pointed to by records
+*/
+func Unmarshal(r io.Reader, records interface{}) error {
+	reader, err := NewReader(r, records)
+	if err != nil {
+		return err
+	}
+	return reader.ReadAll(records)
+}
+
+/*
+This writes records to a csv stream, one struct at a time
+*/
+type Writer struct {
+	writer *csv.Writer
+	typ    reflect.Type
+	fields []goflect.Info
+	header bool
+}
+
+/*
+This creates a writer for the type of the provided record.  The header is written along with the first record
+*/
+func NewWriter(w io.Writer, record interface{}) *Writer {
+	typ := recordType(record)
+	fields := make([]goflect.Info, 0)
+	for _, field := range goflect.GetInfo(reflect.New(typ).Interface()) {
+		if field.IsHidden {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].FieldOrder < fields[j].FieldOrder })
+	return &Writer{writer: csv.NewWriter(w), typ: typ, fields: fields}
+}
+
+/*
+This writes the header row, if it has not been written already
+*/
+func (w *Writer) WriteHeader() error {
+	if w.header {
+		return nil
+	}
+	w.header = true
+	names := make([]string, 0)
+	for _, field := range w.fields {
+		names = append(names, headerName(field))
+	}
+	return w.writer.Write(names)
+}
+
+/*
+This writes a single record.  The output is buffered, so Flush must be called when done
+*/
+func (w *Writer) Write(record interface{}) error {
+	val := reflect.ValueOf(record)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Type() != w.typ {
+		return CsvError{Message: "Write requires a " + w.typ.Name()}
+	}
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+	cells := make([]string, 0)
+	for _, field := range w.fields {
+		if field.IsRedacted {
+			cells = append(cells, REDACTED)
+			continue
+		}
+		cells = append(cells, formatField(val.FieldByName(field.Name), field))
+	}
+	return w.writer.Write(cells)
+}
+
+/*
+This writes every record in the provided slice, and flushes the output
+*/
+func (w *Writer) WriteAll(records interface{}) error {
+	val := reflect.ValueOf(records)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+	for i := 0; i < val.Len(); i++ {
+		if err := w.Write(val.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+/*
+This flushes any buffered rows to the underlying writer
+*/
+func (w *Writer) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+/*
+This is a convenience function that writes a slice of records, with a header, to a csv stream
+*/
+func Marshal(w io.Writer, records interface{}) error {
+	return NewWriter(w, records).WriteAll(records)
+}
+
+/*
+This sets a field from a cell.  An empty cell is the zero value, or NULL for a pointer or a sql.Null field, which otherwise gets a new pointer or is marked Valid
+*/
+func setField(fieldVal reflect.Value, field goflect.Info, value string) error {
+	if value == "" {
+		fieldVal.Set(reflect.Zero(fieldVal.Type()))
+		return nil
+	}
+	if !field.IsOptional {
+		return setValue(fieldVal, field.Kind, value)
+	}
+	if fieldVal.Kind() == reflect.Ptr {
+		held := reflect.New(fieldVal.Type().Elem())
+		if err := setValue(held.Elem(), field.Kind, value); err != nil {
+			return err
+		}
+		fieldVal.Set(held)
+		return nil
+	}
+	held := reflect.New(fieldVal.Type()).Elem()
+	if err := setValue(held.Field(0), field.Kind, value); err != nil {
+		return err
+	}
+	held.FieldByName("Valid").SetBool(true)
+	fieldVal.Set(held)
+	return nil
+}
+
+func setValue(fieldVal reflect.Value, kind reflect.Kind, value string) error {
+	switch kind {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fieldVal.SetBool(b)
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		i, err := strconv.ParseInt(value, 10, fieldVal.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldVal.SetInt(i)
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		u, err := strconv.ParseUint(value, 10, fieldVal.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldVal.SetUint(u)
+	case reflect.Float64, reflect.Float32:
+		f, err := strconv.ParseFloat(value, fieldVal.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fieldVal.SetFloat(f)
+	case reflect.String:
+		fieldVal.SetString(value)
+	default:
+		return fmt.Errorf("Unsupported kind %v", kind)
+	}
+	return nil
+}
+
+/*
+This formats a field as a cell.  NULL is written as an empty cell, and a pointer or a sql.Null field is written as the value it holds
+*/
+func formatField(fieldVal reflect.Value, field goflect.Info) string {
+	if field.IsOptional {
+		if fieldVal.Kind() == reflect.Ptr {
+			if fieldVal.IsNil() {
+				return ""
+			}
+			fieldVal = fieldVal.Elem()
+		} else {
+			if !fieldVal.FieldByName("Valid").Bool() {
+				return ""
+			}
+			fieldVal = fieldVal.Field(0)
+		}
+	}
+	switch fieldVal.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(fieldVal.Bool())
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return strconv.FormatInt(fieldVal.Int(), 10)
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return strconv.FormatUint(fieldVal.Uint(), 10)
+	case reflect.Float64, reflect.Float32:
+		return strconv.FormatFloat(fieldVal.Float(), 'g', -1, fieldVal.Type().Bits())
+	case reflect.String:
+		return fieldVal.String()
+	}
+	return fmt.Sprint(fieldVal.Interface())
+}
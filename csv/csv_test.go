@@ -0,0 +1,181 @@
+package goflect
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type Account struct {
+	Id       int64   `sql:"primary,autoincrement" order:"1"`
+	Name     string  `ui-name:"Account Name" order:"2" valid:"Name != \"\""`
+	Password string  `ui:"redacted" order:"4"`
+	Balance  float64 `sql-column:"balance" default:"10.5" order:"3"`
+	Active   bool    `default:"true" order:"5"`
+	Internal int     `ui:"hidden" order:"6"`
+}
+
+/*
+This shows how to read a csv file into a slice of structs.  The header can use the ui-name, the sql-column or the field name
+*/
+func ExampleUnmarshal() {
+	input := "Id,Account Name,balance,Active\n" +
+		"1,Alice,100.25,false\n" +
+		"2,Bob,,\n"
+
+	accounts := make([]Account, 0)
+	err := Unmarshal(strings.NewReader(input), &accounts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, account := range accounts {
+		fmt.Println(account)
+	}
+
+	//Output:
+	//{1 Alice  100.25 false 0}
+	//{2 Bob  10.5 true 0}
+}
+
+/*
+This shows how to write a slice of structs.  Hidden fields are skipped, and redacted fields are masked
+*/
+func ExampleMarshal() {
+	accounts := []Account{
+		{Id: 1, Name: "Alice", Password: "secret", Balance: 100.25, Internal: 7},
+		{Id: 2, Name: "Bob, Jr.", Password: "hunter2", Balance: 3, Active: true},
+	}
+
+	buffer := new(bytes.Buffer)
+	err := Marshal(buffer, accounts)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(buffer.String())
+
+	//Output:
+	//Id,Account Name,Balance,Password,Active
+	//1,Alice,100.25,********,false
+	//2,"Bob, Jr.",3,********,true
+}
+
+func TestReadErrors(t *testing.T) {
+	assertError := func(input string, expected string) {
+		accounts := make([]*Account, 0)
+		err := Unmarshal(strings.NewReader(input), &accounts)
+		if err == nil {
+			t.Errorf("Expected an error for %q", input)
+			return
+		}
+		if err.Error() != expected {
+			t.Errorf("got:%v, want:%v", err.Error(), expected)
+		}
+	}
+
+	assertError("Id,Bacon\n", "row 1, column 2 (Bacon): Unknown column")
+	assertError("Id,Name\n1,Alice\nfoo,Bob\n", "row 3, column 1 (Id): strconv.ParseInt: parsing \"foo\": invalid syntax")
+	assertError("Id,Active,Name\n1,true,Alice\n2,false,\n", "row 3, column 3 (Name): Failed validation: Name != \"\"")
+	assertError("Id\n1\n", "row 2 (Name): Failed validation: Name != \"\"")
+	assertError("Id,,Name\n1,x,Alice\nfoo,y,Bob\n", "row 3, column 1 (Id): strconv.ParseInt: parsing \"foo\": invalid syntax")
+	assertError(",Id,Name\n,1,Alice\n,2,\n", "row 3, column 3 (Name): Failed validation: Name != \"\"")
+}
+
+func TestReadPointers(t *testing.T) {
+	input := "Name,Internal,Password\nAlice,3,secret\n"
+	reader, err := NewReader(strings.NewReader(input), &Account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := Account{}
+	err = reader.Read(account)
+	if err == nil {
+		t.Error("Expected an error reading into a non pointer")
+	}
+
+	err = reader.Read(&account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Account{Name: "Alice", Password: "secret", Balance: 10.5, Active: true, Internal: 3}
+	if account != expected {
+		t.Errorf("got:%v, want:%v", account, expected)
+	}
+
+	err = reader.Read(&account)
+	if err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	type Simple struct {
+		I   int
+		U8  uint8
+		F32 float32
+		B   bool
+		S   string
+	}
+	expected := []Simple{{1, 2, 3.5, true, "Hello"}, {-1, 255, -0.25, false, "\"Quoted\", with comma"}}
+
+	buffer := new(bytes.Buffer)
+	err := Marshal(buffer, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved := make([]Simple, 0)
+	err = Unmarshal(buffer, &retrieved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retrieved) != len(expected) {
+		t.Fatalf("Expected %v records, found %v", len(expected), len(retrieved))
+	}
+	for i := range expected {
+		if retrieved[i] != expected[i] {
+			t.Errorf("got:%v, want:%v", retrieved[i], expected[i])
+		}
+	}
+}
+
+func TestRoundTripOptional(t *testing.T) {
+	type Optional struct {
+		Id   int64
+		Peer *int64
+		Nick sql.NullString
+	}
+	peer := int64(-7)
+	expected := []Optional{{1, &peer, sql.NullString{String: "Bob, Jr.", Valid: true}}, {2, nil, sql.NullString{}}}
+
+	buffer := new(bytes.Buffer)
+	err := Marshal(buffer, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buffer.String() != "Id,Peer,Nick\n1,-7,\"Bob, Jr.\"\n2,,\n" {
+		t.Errorf("NULL is an empty cell, and values are written as they are held, got %q", buffer.String())
+	}
+	retrieved := make([]Optional, 0)
+	err = Unmarshal(buffer, &retrieved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retrieved) != len(expected) {
+		t.Fatalf("Expected %v records, found %v", len(expected), len(retrieved))
+	}
+	if retrieved[0].Peer == nil || *retrieved[0].Peer != peer || retrieved[0].Nick != expected[0].Nick {
+		t.Errorf("got:%v, want:%v", retrieved[0], expected[0])
+	}
+	if retrieved[1].Peer != nil || retrieved[1].Nick.Valid {
+		t.Errorf("Expected NULLs, got:%v", retrieved[1])
+	}
+
+	if err := Unmarshal(strings.NewReader("Id,Peer\n1,x\n"), &retrieved); err == nil {
+		t.Error("Expected an error for a pointer field that does not parse")
+	}
+}
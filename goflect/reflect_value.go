@@ -1,6 +1,7 @@
 package goflect
 
 import (
+	"git.sevone.com/sdevlin/goflect.git/matcher"
 	"reflect"
 	"strconv"
 	"strings"
@@ -9,11 +10,13 @@ import (
 type reflectValue reflect.StructField
 
 /*
-This is used to determine the Field Info using reflection on a structure.  It will use the field's name as the name, and the field's type's Kind as the Kind
+This is used to determine the Field Info using reflection on a structure.  It will use the field's name as the name, and the field's type's Kind as the Kind.  Pointers and the sql.Null types are optional, and take the Kind of the value they hold
 */
 func (field reflectValue) GetFieldInfo() (output FieldInfo) {
 	output.Name = field.Name
-	output.Kind = field.Type.Kind()
+	held, optional := matcher.NullableType(field.Type)
+	output.Kind = held.Kind()
+	output.IsOptional = optional
 	return output
 }
 
@@ -23,11 +26,12 @@ This is used to generate a SqlInfo field using reflection.  There is a struct ta
     primary - denotes a primary key
     autoincrement - denotes that the field will be autoincremented by the db
     immutable - denotes that the field will be immutable, so it can't be updated
-    unique - denotes that a fields value will be unique
+    unique - denotes that a fields value will be unique.  It implies not-null, except for a pointer or a sql.Null type, where NULL may repeat
     not-null - denotes that a field cannot be null
     index - denotes that the field is indexed for performance
     nominal - denotes that the field is a name alias for a record
 
+The sql-child and sql-extend tags name the type a field points to.  The sql-on-delete tag controls what happens when the record pointed to is deleted, either cascade or restrict, and record services refuse to define a type with any other value.  An extension is part of the record it extends, so it defaults to cascade, and a child defaults to restrict
 */
 func (field reflectValue) GetFieldSqlInfo() (output SqlInfo) {
 	tags := field.Tag.Get(TAG_SQL)
@@ -36,7 +40,9 @@ func (field reflectValue) GetFieldSqlInfo() (output SqlInfo) {
 	output.IsAutoincrement = strings.Contains(tags, SQL_AUTOINC)
 	output.IsImmutable = strings.Contains(tags, SQL_IMMUTABLE) || output.IsAutoincrement
 	output.IsUnique = strings.Contains(tags, SQL_UNIQUE) || output.IsPrimary
-	output.IsNullable = !(strings.Contains(tags, SQL_NULLABLE) || output.IsUnique)
+	//A unique field cannot be NULL, unless its type says it may hold NULL
+	_, optional := matcher.NullableType(field.Type)
+	output.IsNullable = !(strings.Contains(tags, SQL_NULLABLE) || (output.IsUnique && (output.IsPrimary || !optional)))
 	output.IsIndexed = strings.Contains(tags, SQL_INDEX) || output.IsUnique
 	output.IsNominal = strings.Contains(tags, SQL_NOMINAL)
 	output.IsSqlIgnored = strings.Contains(tags, SQL_IGNORE)
@@ -44,6 +50,13 @@ func (field reflectValue) GetFieldSqlInfo() (output SqlInfo) {
 	output.SqlColumn = field.Tag.Get(TAG_SQL_COLUMN)
 	output.ChildOf = field.Tag.Get(TAG_SQL_CHILD)
 	output.Extends = field.Tag.Get(TAG_SQL_EXTEND)
+	output.OnDelete = field.Tag.Get(TAG_SQL_ON_DELETE)
+	if output.OnDelete == "" && output.Extends != "" {
+		output.OnDelete = SQL_CASCADE
+	}
+	if output.OnDelete == "" && output.ChildOf != "" {
+		output.OnDelete = SQL_RESTRICT
+	}
 
 	return output
 }
@@ -54,14 +67,17 @@ This is used to generate a UiInfo field using reflection.  There are several str
     desc - This stores a human readable description for a tooltip
     default - This stores the default value for the field.  Must be compatible with the type
     order - This controls the order for the field to appear in web forms
+    ui-name - This stores the name shown to the user, such as a form label or a column header
 
 There is also a "flag tag", "ui", with the following entries possible
 
     hidden - This controls if the user can see the field
+    redacted - This controls if the field is shown as stars
 */
 func (field reflectValue) GetFieldUiInfo() (output UiInfo) {
 	output.Description = field.Tag.Get(TAG_DESC)
 	output.Default = field.Tag.Get(TAG_DEFAULT)
+	output.DisplayName = field.Tag.Get(TAG_UI_NAME)
 	output.FieldOrder, _ = strconv.ParseInt(field.Tag.Get(TAG_ORDER), 0, 64)
 
 	tags := field.Tag.Get(TAG_UI)
@@ -1,6 +1,7 @@
 package goflect
 
 import (
+	"database/sql"
 	"fmt"
 	"testing"
 )
@@ -86,6 +87,33 @@ func TestSqlFields(t *testing.T) {
 		IsNullable: true,
 		SqlColumn:  "Bacon",
 	}, &T09{})
+
+	type T10 struct {
+		Id int `sql-child:"Peer"`
+	}
+	compare(SqlInfo{
+		IsNullable: true,
+		ChildOf:    "Peer",
+		OnDelete:   "restrict",
+	}, &T10{})
+
+	type T11 struct {
+		Id int `sql-extend:"Device"`
+	}
+	compare(SqlInfo{
+		IsNullable: true,
+		Extends:    "Device",
+		OnDelete:   "cascade",
+	}, &T11{})
+
+	type T12 struct {
+		Id int `sql-child:"Peer" sql-on-delete:"cascade"`
+	}
+	compare(SqlInfo{
+		IsNullable: true,
+		ChildOf:    "Peer",
+		OnDelete:   "cascade",
+	}, &T12{})
 }
 
 func TestValidatorFields(t *testing.T) {
@@ -134,6 +162,22 @@ func ExampleReflectValue_GetFieldInfo() {
 	//Output: Id int64
 }
 
+func ExampleGetInfo_optional() {
+	type Bar struct {
+		Id      int64
+		Manager *int64
+		Nick    sql.NullString
+	}
+
+	for _, field := range GetInfo(&Bar{}) {
+		fmt.Println(field.Name, field.Kind, field.IsOptional)
+	}
+	//Output:
+	//Id int64 false
+	//Manager int64 true
+	//Nick string true
+}
+
 func ExampleReflectValue_GetFieldSqlInfo_primary() {
 	type Bar struct {
 		Id int64 `sql:"primary,autoincrement"`
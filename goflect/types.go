@@ -10,6 +10,7 @@ const (
 	TAG_SQL_COLUMN        = "sql-column"
 	TAG_SQL_CHILD         = "sql-child"
 	TAG_SQL_EXTEND        = "sql-extend"
+	TAG_SQL_ON_DELETE     = "sql-on-delete"
 	TAG_VALID             = "valid"
 	TAG_DEFAULT           = "default"
 	TAG_ORDER             = "order"
@@ -28,6 +29,11 @@ const (
 	SQL_IGNORE           = "ignored"
 )
 
+const (
+	SQL_CASCADE  string = "cascade"
+	SQL_RESTRICT        = "restrict"
+)
+
 const (
 	UI_HIDDEN   string = "hidden"
 	UI_REDACTED        = "redacted"
@@ -42,6 +48,7 @@ var (
 		TAG_SQL_COLUMN,
 		TAG_SQL_CHILD,
 		TAG_SQL_EXTEND,
+		TAG_SQL_ON_DELETE,
 		TAG_UI,
 		TAG_UI_NAME,
 		TAG_ORDER,
@@ -63,11 +70,12 @@ var (
 )
 
 /*
-The FieldInfo struct is used to store two pieces of information about the field, its name and Kind.
+The FieldInfo struct is used to store the basic information about the field, its name, its Kind, and whether it can be NULL.
 */
 type FieldInfo struct {
-	Name string       `desc:"This is the name of the field in the struct.  It is authoritative" sql:"primary"`
-	Kind reflect.Kind `desc:"This is the golang kind, from the reflect pacakge.  It controls dispatch"`
+	Name       string       `desc:"This is the name of the field in the struct.  It is authoritative" sql:"primary"`
+	Kind       reflect.Kind `desc:"This is the golang kind, from the reflect pacakge.  It controls dispatch"`
+	IsOptional bool         `desc:"This indicates the field is a pointer or a sql.Null type, which holds a value of Kind or NULL"`
 }
 
 type SqlInfo struct {
@@ -83,6 +91,7 @@ type SqlInfo struct {
 	SqlColumn       string `desc:"This is the actual sql column to use.  Leaving it blank to allow the engine to determine the value based on the Name property"`
 	ChildOf         string `desc:"This describes the child relationship that a record has.  It points to a type"`
 	Extends         string `desc:"This describes the extension relationship that a table has"`
+	OnDelete        string `desc:"This controls what happens to the record when the record it points to is deleted.  It is either cascade or restrict"`
 }
 
 type ValidatorInfo struct {
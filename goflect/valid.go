@@ -3,6 +3,7 @@ package goflect
 import (
 	"git.sevone.com/sdevlin/goflect.git/matcher"
 	"reflect"
+	"time"
 )
 
 /*
@@ -26,7 +27,7 @@ func DefaultMatcher(record interface{}) (matcher.Matcher, error) {
 }
 
 /*
-This uses the provided record to create a context for the parser.  It the record is a struct, reflection is used.  If the record is a primitive, its type is used and a primitive parser is return instead
+This uses the provided record to create a context for the parser.  It the record is a struct, reflection is used, and the fields of nested structs are available by dotted path, such as Location.Name.  If the record is a primitive, its type is used and a primitive parser is return instead
 */
 func DefaultParser(record interface{}) (matcher.Parser, error) {
 	typ := reflect.TypeOf(record)
@@ -37,9 +38,13 @@ func DefaultParser(record interface{}) (matcher.Parser, error) {
 
 	if kind == reflect.Interface || kind == reflect.Struct {
 
-		fields := GetInfo(record)
+		if kind == reflect.Struct {
+			types := make(map[string]reflect.Type)
+			addTypes(types, "", typ, map[reflect.Type]int{typ: 1})
+			return matcher.NewParser(types)
+		}
 		kinds := make(map[string]reflect.Kind)
-		for _, field := range fields {
+		for _, field := range GetInfo(record) {
 			kinds[field.Name] = field.Kind
 		}
 		return matcher.NewParser(kinds)
@@ -58,3 +63,22 @@ func Parse(record interface{}, input string) (matcher.Matcher, error) {
 	}
 	return p.Parse(input)
 }
+
+/*
+This binds the fields of the struct to their types, and recurses into fields that are structs, or pointers to them.  The sql.Null types are values like the ones they hold, and times are values rather than structs to recurse into.  A type that contains itself is only followed one level into itself, so that a path cannot go on forever
+*/
+func addTypes(types map[string]reflect.Type, prefix string, typ reflect.Type, seen map[reflect.Type]int) {
+	for _, field := range GetInfo(reflect.StructField{Type: typ}) {
+		structField, _ := typ.FieldByName(field.Name)
+		types[prefix+field.Name] = structField.Type
+		nested, _ := matcher.NullableType(structField.Type)
+		if nested.Kind() != reflect.Struct || nested == timeType || seen[nested] > 1 {
+			continue
+		}
+		seen[nested]++
+		addTypes(types, prefix+field.Name+".", nested, seen)
+		seen[nested]--
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
@@ -3,6 +3,7 @@ package goflect
 import (
 	"fmt"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"time"
 )
 
 /*
@@ -67,3 +68,58 @@ func ExampleDefaultMatcher_1() {
 	//The password request failed, the wrong password repeated
 	//The password request failed, the secret was not changed
 }
+
+/*
+The fields of nested structs can be named by path, including through pointers.  A type that refers to itself is only followed one level deep
+*/
+func ExampleParse_paths() {
+	type Address struct {
+		City string
+	}
+	type Person struct {
+		Name    string
+		Home    Address
+		Work    *Address
+		Manager *Person
+	}
+
+	m, err := Parse(&Person{}, `Home.City = Work.City AND Manager.Name != Name`)
+	fmt.Println(err)
+	fmt.Println(m.Match(Person{
+		Name:    "Ann",
+		Home:    Address{City: "Leeds"},
+		Work:    &Address{City: "Leeds"},
+		Manager: &Person{Name: "Bob"},
+	}))
+
+	_, err = Parse(&Person{}, `Manager.Manager.Name = "Cy"`)
+	fmt.Println(err)
+
+	//Output:
+	//<nil>
+	//true <nil>
+	//Unknown Field provided: Manager.Manager.Name
+}
+
+/*
+Fields that are times or durations can be compared with literals, and with the time the matcher runs
+*/
+func ExampleParse_times() {
+	type Session struct {
+		Started time.Time
+		Expires time.Time
+		Idle    time.Duration
+	}
+
+	m, err := Parse(&Session{}, `Expires > now AND Started >= 2020-01-01T00:00:00Z AND Idle < 15m`)
+	fmt.Println(err)
+	fmt.Println(m.Match(Session{
+		Started: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		Expires: time.Now().Add(time.Hour),
+		Idle:    time.Minute,
+	}))
+
+	//Output:
+	//<nil>
+	//true <nil>
+}
@@ -0,0 +1,121 @@
+package matcher
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+This is one leaf of a matcher that did not hold for a record.  Field is the path to the value that was compared, and is empty when the record itself was.  Op and Expected are the comparison as the default printer renders it, Actual is the value that was found or NULL, and Expression is the whole leaf
+*/
+type Failure struct {
+	Field      string
+	Op         string
+	Expected   string
+	Actual     string
+	Expression string
+}
+
+func (f Failure) String() string {
+	return f.Expression + ", but " + f.name() + " is " + f.Actual
+}
+
+func (f Failure) name() string {
+	if f.Field == "" {
+		return "_"
+	}
+	return f.Field
+}
+
+/*
+This evaluates the matcher against the record, and returns the leaves that failed.  An empty list means the record matches.  Every failing clause of an And is reported, while an Or only reports its clauses when none of them hold.  Errors are returned the same way Match would return them
+*/
+func Explain(m Matcher, record interface{}) ([]Failure, error) {
+	return explain(m, record, "")
+}
+
+func explain(m Matcher, record interface{}, path string) ([]Failure, error) {
+	switch r := m.(type) {
+	case anyMatch:
+		return nil, nil
+	case andMatch:
+		output := make([]Failure, 0)
+		for _, matcher := range r.Matchers {
+			failures, err := explain(matcher, record, path)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, failures...)
+		}
+		return output, nil
+	case orMatch:
+		output := make([]Failure, 0)
+		for _, matcher := range r.Matchers {
+			failures, err := explain(matcher, record, path)
+			if err != nil {
+				return nil, err
+			}
+			if len(failures) == 0 {
+				return nil, nil
+			}
+			output = append(output, failures...)
+		}
+		return output, nil
+	case *structMatcher:
+		//The record is kept for the yielders, just as Match does
+		r.record = record
+		names := make([]string, 0, len(r.Fields))
+		for name := range r.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		output := make([]Failure, 0)
+		for _, name := range names {
+			attr, err := lookup(record, name)
+			if err == errNullPath {
+				attr, err = nil, nil
+			}
+			if err != nil {
+				return nil, InvalidCompare(1)
+			}
+			child := name
+			if path != "" {
+				child = path + "." + name
+			}
+			failures, err := explain(r.Fields[name], attr, child)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, failures...)
+		}
+		return output, nil
+	}
+
+	ok, err := m.Match(record)
+	if err != nil || ok {
+		return nil, err
+	}
+	failure := Failure{Field: path, Actual: "NULL"}
+	if held, isNull := unwrapNull(record); !isNull {
+		failure.Actual = printValue(held)
+	}
+	switch r := m.(type) {
+	case fieldMatcher:
+		failure.Op = r.Op.String()
+		if !r.Op.isNullCheck() {
+			failure.Expected = printValue(r.Value)
+		}
+	case invertMatch:
+		failure.Op = "NOT"
+		failure.Expected, _ = defaultPrinter{v: path}.Print(r.M)
+	case noneMatch:
+		failure.Expected = "false"
+	default:
+		failure.Expected = fmt.Sprint(m)
+	}
+	failure.Expression, _ = defaultPrinter{v: path}.Print(m)
+	if failure.Expression == "" {
+		failure.Expression = failure.Expected
+	}
+	return []Failure{failure}, nil
+}
@@ -0,0 +1,93 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func ExampleExplain() {
+	//This shows which clauses of a parsed expression a record fails, and why
+	type Port struct {
+		Name   string
+		Number int
+	}
+	p, _ := NewParser(map[string]reflect.Kind{"Name": reflect.String, "Number": reflect.Int})
+	matcher, _ := p.Parse(`Name != "" AND Number > 0 AND Number < 1024`)
+
+	failures, _ := Explain(matcher, Port{Name: "", Number: 8080})
+	for _, failure := range failures {
+		fmt.Println(failure)
+	}
+	failures, _ = Explain(matcher, Port{Name: "ssh", Number: 22})
+	fmt.Println(len(failures))
+
+	//Output:
+	//Name != "", but Name is ""
+	//Number < 1024, but Number is 8080
+	//0
+}
+
+func ExampleExplain_or() {
+	//An Or only fails when every clause does, and then each clause is reported
+	matcher := NewStructMatcher()
+	matcher.AddField("Port", Or(Eq(22), Eq(80)))
+
+	failures, _ := Explain(matcher, map[string]interface{}{"Port": 443})
+	for _, failure := range failures {
+		fmt.Println(failure.Field, failure.Op, failure.Expected, failure.Actual)
+	}
+
+	//Output:
+	//Port = 22 443
+	//Port = 80 443
+}
+
+func TestExplain(t *testing.T) {
+	record := struct {
+		A int
+		B string
+	}{A: 5, B: "x"}
+
+	field := func(name string, m Matcher) Matcher {
+		output := NewStructMatcher()
+		output.AddField(name, m)
+		return output
+	}
+	cases := []struct {
+		m        Matcher
+		expected []string
+	}{
+		{Any(), []string{}},
+		{None(), []string{"false, but _ is {5 x}"}},
+		{field("A", Gt(10)), []string{"A > 10, but A is 5"}},
+		{field("A", Lt(10)), []string{}},
+		{field("B", In([]string{"y", "z"})), []string{`B IN ["y" "z"], but B is "x"`}},
+		{field("A", invertMatch{M: Or(Eq(5), Eq(6))}), []string{"NOT (A = 5 OR A = 6), but A is 5"}},
+		{Or(field("A", Eq(1)), field("B", Eq("x"))), []string{}},
+	}
+	for _, c := range cases {
+		printed, _ := NewDefaultPrinter().Print(c.m)
+		matched, _ := c.m.Match(record)
+		failures, err := Explain(c.m, record)
+		if err != nil {
+			t.Errorf("Unexpected error explaining %v: %v", printed, err)
+			continue
+		}
+		if matched != (len(failures) == 0) {
+			t.Errorf("Explain disagrees with Match for %v: %v", printed, failures)
+		}
+		actual := make([]string, 0)
+		for _, failure := range failures {
+			actual = append(actual, failure.String())
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected %v to explain as %v, got %v", printed, c.expected, actual)
+		}
+	}
+
+	_, err := Explain(field("Missing", Eq(1)), record)
+	if err == nil {
+		t.Errorf("Missing fields should be an error")
+	}
+}
@@ -0,0 +1,299 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+/*
+This is the wire format of a matcher.  Every node has a type, which is one of any, none, error, and, or, not, struct or field, and only the members that the type needs are set
+
+    {"type": "and", "matchers": [...]}
+    {"type": "not", "matcher": {...}}
+    {"type": "struct", "fields": {"Name": {...}}}
+    {"type": "field", "op": ">=", "kind": "int64", "value": 10}
+    {"type": "field", "op": "IN", "kind": "[]string", "value": ["a", "b"]}
+    {"type": "field", "op": "=", "field": "Other"}
+    {"type": "field", "op": ">=", "relative": "-5m0s"}
+
+Field ops are written as the default printer writes them.  The kind is the Go type of the value, since matchers only compare values of the same type, and a field reference names another field of the enclosing struct matcher.  Times are RFC3339 and durations are nanoseconds, and a relative time is the offset from when the matcher runs, as a duration string
+*/
+type jsonMatcher struct {
+	Type     string                 `json:"type"`
+	Matchers []jsonMatcher          `json:"matchers,omitempty"`
+	Matcher  *jsonMatcher           `json:"matcher,omitempty"`
+	Fields   map[string]jsonMatcher `json:"fields,omitempty"`
+	Op       string                 `json:"op,omitempty"`
+	Kind     string                 `json:"kind,omitempty"`
+	Value    json.RawMessage        `json:"value,omitempty"`
+	Field    string                 `json:"field,omitempty"`
+	Relative string                 `json:"relative,omitempty"`
+}
+
+//These are the types a field matcher value may have on the wire, keyed by name
+var jsonKinds = func() map[string]reflect.Type {
+	output := make(map[string]reflect.Type)
+	for _, v := range []interface{}{
+		int(0), int64(0), int32(0), int16(0), int8(0),
+		uint(0), uint64(0), uint32(0), uint16(0), uint8(0),
+		float64(0), float32(0), "", false,
+		time.Time{}, time.Duration(0),
+	} {
+		typ := reflect.TypeOf(v)
+		output[typ.String()] = typ
+		output[reflect.SliceOf(typ).String()] = reflect.SliceOf(typ)
+	}
+	return output
+}()
+
+/*
+This encodes the matcher as JSON, so that it can be stored or sent elsewhere, and read back with FromJSON.  Lambdas, yielders other than struct fields, and matchers from outside this package cannot be encoded, and return an error.  The matchers in this package also implement json.Marshaler with this encoding, so they can be written as part of a larger document
+*/
+func ToJSON(m Matcher) ([]byte, error) {
+	node, err := encodeMatcher(m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(node)
+}
+
+/*
+This decodes a matcher written by ToJSON.  The result matches the same records as the original, and prints the same way
+*/
+func FromJSON(data []byte) (Matcher, error) {
+	node := jsonMatcher{}
+	err := json.Unmarshal(data, &node)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMatcher(node, nil)
+}
+
+func encodeMatcher(m Matcher) (jsonMatcher, error) {
+	switch r := m.(type) {
+	case anyMatch:
+		return jsonMatcher{Type: "any"}, nil
+	case noneMatch:
+		return jsonMatcher{Type: "none"}, nil
+	case errorMatch:
+		return jsonMatcher{Type: "error"}, nil
+	case andMatch:
+		return encodeList("and", r.Matchers)
+	case orMatch:
+		return encodeList("or", r.Matchers)
+	case invertMatch:
+		inner, err := encodeMatcher(r.M)
+		if err != nil {
+			return jsonMatcher{}, err
+		}
+		return jsonMatcher{Type: "not", Matcher: &inner}, nil
+	case *structMatcher:
+		output := jsonMatcher{Type: "struct", Fields: make(map[string]jsonMatcher)}
+		for name, matcher := range r.Fields {
+			field, err := encodeMatcher(matcher)
+			if err != nil {
+				return jsonMatcher{}, err
+			}
+			output.Fields[name] = field
+		}
+		return output, nil
+	case fieldMatcher:
+		output := jsonMatcher{Type: "field", Op: r.Op.String()}
+		if r.Op.isNullCheck() {
+			return output, nil
+		}
+		if y, ok := r.Value.(fieldYielder); ok {
+			output.Field = y.Name
+			return output, nil
+		}
+		if relative, ok := r.Value.(relativeTime); ok {
+			//An offset of zero is still written, so that the node is not mistaken for a value
+			output.Relative = time.Duration(relative).String()
+			return output, nil
+		}
+		typ := reflect.TypeOf(r.Value)
+		if typ == nil || jsonKinds[typ.String()] != typ {
+			return jsonMatcher{}, fmt.Errorf("Cannot encode a %v %v value as JSON", output.Op, typ)
+		}
+		value, err := json.Marshal(r.Value)
+		if err != nil {
+			return jsonMatcher{}, err
+		}
+		output.Kind = typ.String()
+		output.Value = value
+		return output, nil
+	}
+	return jsonMatcher{}, fmt.Errorf("Cannot encode a %T as JSON", m)
+}
+
+func encodeList(kind string, matchers []Matcher) (jsonMatcher, error) {
+	output := jsonMatcher{Type: kind, Matchers: make([]jsonMatcher, 0, len(matchers))}
+	for _, matcher := range matchers {
+		child, err := encodeMatcher(matcher)
+		if err != nil {
+			return jsonMatcher{}, err
+		}
+		output.Matchers = append(output.Matchers, child)
+	}
+	return output, nil
+}
+
+/*
+This rebuilds a matcher from its wire format.  Scope is the innermost struct matcher, which field references are resolved against
+*/
+func decodeMatcher(node jsonMatcher, scope *structMatcher) (Matcher, error) {
+	switch node.Type {
+	case "any":
+		return Any(), nil
+	case "none":
+		return None(), nil
+	case "error":
+		return Buggy(), nil
+	case "and", "or":
+		matchers, err := decodeList(node.Matchers, scope)
+		if err != nil {
+			return nil, err
+		}
+		if len(matchers) < 2 {
+			return nil, fmt.Errorf("JSON %v needs at least two matchers, got %v", node.Type, len(matchers))
+		}
+		if node.Type == "and" {
+			return andMatch{Matchers: matchers}, nil
+		}
+		return orMatch{Matchers: matchers}, nil
+	case "not":
+		if node.Matcher == nil {
+			return nil, fmt.Errorf("JSON not needs a matcher")
+		}
+		inner, err := decodeMatcher(*node.Matcher, scope)
+		if err != nil {
+			return nil, err
+		}
+		return invertMatch{M: inner}, nil
+	case "struct":
+		output := new(structMatcher)
+		output.Fields = make(map[string]Matcher)
+		for name, child := range node.Fields {
+			field, err := decodeMatcher(child, output)
+			if err != nil {
+				return nil, err
+			}
+			output.Fields[name] = field
+		}
+		return output, nil
+	case "field":
+		return decodeField(node, scope)
+	}
+	return nil, fmt.Errorf("Unknown JSON matcher type %q", node.Type)
+}
+
+func decodeList(nodes []jsonMatcher, scope *structMatcher) ([]Matcher, error) {
+	output := make([]Matcher, 0, len(nodes))
+	for _, node := range nodes {
+		matcher, err := decodeMatcher(node, scope)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, matcher)
+	}
+	return output, nil
+}
+
+func decodeField(node jsonMatcher, scope *structMatcher) (Matcher, error) {
+	op, present := jsonOps[node.Op]
+	if !present {
+		return nil, fmt.Errorf("Unknown JSON field op %q", node.Op)
+	}
+	if op.isNullCheck() {
+		return fieldMatcher{Op: op}, nil
+	}
+	if op.isList() && (node.Field != "" || node.Relative != "") {
+		return nil, fmt.Errorf("JSON %v needs a list value", node.Op)
+	}
+	if node.Field != "" {
+		if scope == nil {
+			return nil, fmt.Errorf("JSON field reference %v is not inside a struct", node.Field)
+		}
+		return fieldMatcher{Op: op, Value: scope.Field(node.Field)}, nil
+	}
+	if node.Relative != "" {
+		offset, err := time.ParseDuration(node.Relative)
+		if err != nil {
+			return nil, err
+		}
+		return fieldMatcher{Op: op, Value: relativeTime(offset)}, nil
+	}
+	typ, present := jsonKinds[node.Kind]
+	if !present {
+		return nil, fmt.Errorf("Unknown JSON value kind %q", node.Kind)
+	}
+	if node.Value == nil {
+		return nil, fmt.Errorf("JSON %v %v has no value", node.Op, node.Kind)
+	}
+	if op.isList() != (typ.Kind() == reflect.Slice) {
+		return nil, fmt.Errorf("JSON %v cannot take a %v value", node.Op, node.Kind)
+	}
+	value := reflect.New(typ)
+	err := json.Unmarshal(node.Value, value.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if (op == BETWEEN || op == NOT_BETWEEN) && value.Elem().Len() != 2 {
+		return nil, fmt.Errorf("JSON %v needs 2 values, got %v", node.Op, value.Elem().Len())
+	}
+	return fieldMatcher{Op: op, Value: value.Elem().Interface()}, nil
+}
+
+/*
+This returns whether the op takes a list of values rather than a single value
+*/
+func (op fieldOps) isList() bool {
+	switch op {
+	case IN, NOT_IN, BETWEEN, NOT_BETWEEN:
+		return true
+	}
+	return false
+}
+
+//These are the field ops, keyed by how they are printed
+var jsonOps = func() map[string]fieldOps {
+	output := make(map[string]fieldOps)
+	for op := LT; op <= NOT_IEQ; op++ {
+		output[op.String()] = op
+	}
+	return output
+}()
+
+func (a anyMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (a noneMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (a errorMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (a andMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (a orMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (a invertMatch) MarshalJSON() ([]byte, error) {
+	return ToJSON(a)
+}
+
+func (field *structMatcher) MarshalJSON() ([]byte, error) {
+	return ToJSON(field)
+}
+
+func (field fieldMatcher) MarshalJSON() ([]byte, error) {
+	return ToJSON(field)
+}
@@ -0,0 +1,149 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ExampleToJSON() {
+	//This shows the wire format of a parsed expression, and reads it back
+	p, _ := NewParser(map[string]reflect.Kind{"Name": reflect.String, "Port": reflect.Int})
+	matcher, _ := p.Parse(`Name IN ("ssh", "http") AND Port >= 22`)
+
+	data, _ := ToJSON(matcher)
+	fmt.Println(string(data))
+
+	decoded, _ := FromJSON(data)
+	printed, _ := NewDefaultPrinter().Print(decoded)
+	fmt.Println(printed)
+
+	//Output:
+	//{"type":"and","matchers":[{"type":"struct","fields":{"Name":{"type":"field","op":"IN","kind":"[]string","value":["ssh","http"]}}},{"type":"struct","fields":{"Port":{"type":"field","op":"\u003e=","kind":"int","value":22}}}]}
+	//Name IN ["ssh" "http"] AND Port >= 22
+}
+
+func ExampleFromJSON() {
+	//Field references are resolved against the struct matcher that contains them
+	matcher, _ := FromJSON([]byte(`{"type": "struct", "fields": {"Used": {"type": "field", "op": "<=", "field": "Size"}}}`))
+
+	fmt.Println(matcher.Match(map[string]interface{}{"Used": 10, "Size": 20}))
+	fmt.Println(matcher.Match(map[string]interface{}{"Used": 30, "Size": 20}))
+
+	//Output:
+	//true <nil>
+	//false <nil>
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	reference := NewStructMatcher()
+	reference.AddField("A", Gt(reference.Field("B")))
+	nested := NewStructMatcher()
+	nested.AddField("A", Or(Eq(int8(1)), In([]uint64{1 << 63, 2})))
+	nested.AddField("B", invertMatch{M: Or(Match("^a"), Eq(float32(1.5)))})
+
+	matchers := []Matcher{
+		Any(),
+		None(),
+		Buggy(),
+		Eq(true),
+		Lt(int64(-1 << 62)),
+		NotIn([]string{"a", "b"}),
+		NotMatch("x+"),
+		Gte(time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("", 3600))),
+		In([]time.Duration{time.Second, time.Hour}),
+		Lt(Now(-5 * time.Minute)),
+		Eq(Now(0)),
+		IsNull(),
+		Not(IsNotNull()),
+		Like("a%"),
+		NotStartsWith("b_"),
+		Contains("c"),
+		Ieq("D"),
+		Between(int16(1), int16(5)),
+		NotBetween(time.Second, time.Minute),
+		reference,
+		nested,
+		And(nested, Not(reference)),
+	}
+	for _, m := range matchers {
+		expected, _ := NewDefaultPrinter().Print(m)
+		data, err := ToJSON(m)
+		if err != nil {
+			t.Errorf("Could not encode %v: %v", expected, err)
+			continue
+		}
+		decoded, err := FromJSON(data)
+		if err != nil {
+			t.Errorf("Could not decode %s: %v", data, err)
+			continue
+		}
+		actual, _ := NewDefaultPrinter().Print(decoded)
+		if actual != expected {
+			t.Errorf("Expected %v to round trip, got %v", expected, actual)
+		}
+		again, _ := ToJSON(decoded)
+		if string(again) != string(data) {
+			t.Errorf("Expected %s to encode the same way twice, got %s", data, again)
+		}
+		embedded, err := json.Marshal(map[string]interface{}{"rule": m})
+		if err != nil || string(embedded) != `{"rule":`+string(data)+`}` {
+			t.Errorf("Expected json.Marshal to use the same encoding, got %s %v", embedded, err)
+		}
+	}
+
+	record := map[string]interface{}{"A": int8(1), "B": "b"}
+	decoded, _ := FromJSON(mustJSON(t, nested))
+	original, _ := nested.Match(record)
+	result, _ := decoded.Match(record)
+	if original != result {
+		t.Errorf("Decoded matcher does not match like the original")
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	unencodable := []Matcher{
+		Eq(NewLambdaYield(func() (interface{}, error) { return 1, nil })),
+		Eq(struct{}{}),
+		Eq(nil),
+	}
+	for _, m := range unencodable {
+		if _, err := ToJSON(m); err == nil {
+			t.Errorf("Expected an error encoding %#v", m)
+		}
+	}
+
+	invalid := []string{
+		`[`,
+		`{"type": "maybe"}`,
+		`{"type": "and", "matchers": [{"type": "any"}]}`,
+		`{"type": "not"}`,
+		`{"type": "field", "op": "~", "kind": "int", "value": 1}`,
+		`{"type": "field", "op": "=", "kind": "complex128", "value": 1}`,
+		`{"type": "field", "op": "=", "kind": "int"}`,
+		`{"type": "field", "op": "=", "kind": "int8", "value": 300}`,
+		`{"type": "field", "op": "=", "field": "B"}`,
+		`{"type": "field", "op": "IN", "kind": "int64", "value": 1}`,
+		`{"type": "field", "op": "NOT IN", "kind": "string", "value": "a"}`,
+		`{"type": "field", "op": "BETWEEN", "kind": "int64", "value": 1}`,
+		`{"type": "field", "op": "BETWEEN", "kind": "[]int64", "value": [1]}`,
+		`{"type": "field", "op": "BETWEEN", "kind": "[]int64", "value": [1, 2, 3]}`,
+		`{"type": "field", "op": "=", "kind": "[]int64", "value": [1, 2]}`,
+		`{"type": "field", "op": "IN", "relative": "1h"}`,
+	}
+	for _, data := range invalid {
+		if _, err := FromJSON([]byte(data)); err == nil {
+			t.Errorf("Expected an error decoding %v", data)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, m Matcher) []byte {
+	data, err := ToJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
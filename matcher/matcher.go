@@ -4,6 +4,7 @@ import (
 	//"fmt"
 	"reflect"
 	"regexp"
+	"time"
 )
 
 type fieldMatcher struct {
@@ -49,16 +50,40 @@ func (field *fieldMatcher) warmCache() {
 }
 
 func (field fieldMatcher) Match(record interface{}) (bool, error) {
-	field.warmCache()
-	invert := false
+	result, err := field.matchTernary(record)
+	return result == isTrue, err
+}
+
+/*
+This compares the record under three valued logic.  A NULL on either side makes the comparison unknown, except for IS NULL and IS NOT NULL
+*/
+func (field fieldMatcher) matchTernary(record interface{}) (truth, error) {
+	record, null := unwrapNull(record)
 	switch field.Op {
-	case NEQ, NOT_IN, GT, GTE, NOT_MATCH:
-		invert = true
+	case IS_NULL:
+		return truthOf(null), nil
+	case IS_NOT_NULL:
+		return truthOf(!null), nil
 	}
+	field.warmCache()
 	v := field.Value
 	if y, ok := v.(Yielder); ok {
 		v, _ = y.Yield()
 	}
+	v, otherNull := unwrapNull(v)
+	if null || otherNull {
+		return isUnknown, nil
+	}
+	result, err := field.compare(record, v)
+	return truthOf(result), err
+}
+
+func (field fieldMatcher) compare(record, v interface{}) (bool, error) {
+	invert := false
+	switch field.Op {
+	case NEQ, NOT_IN, GT, GTE, NOT_MATCH:
+		invert = true
+	}
 	switch field.Op {
 	case NEQ, EQ:
 		if reflect.TypeOf(record) != reflect.TypeOf(v) {
@@ -93,6 +118,10 @@ func (field fieldMatcher) Match(record interface{}) (bool, error) {
 			return invert != (r == v.(string)), nil
 		case bool:
 			return invert != (r == v.(bool)), nil
+		case time.Time:
+			return invert != r.Equal(v.(time.Time)), nil
+		case time.Duration:
+			return invert != (r == v.(time.Duration)), nil
 		}
 	case GTE, LT:
 		if reflect.TypeOf(record) != reflect.TypeOf(v) {
@@ -125,6 +154,10 @@ func (field fieldMatcher) Match(record interface{}) (bool, error) {
 			return invert != (r < v.(float32)), nil
 		case string:
 			return invert != (r < v.(string)), nil
+		case time.Time:
+			return invert != r.Before(v.(time.Time)), nil
+		case time.Duration:
+			return invert != (r < v.(time.Duration)), nil
 		}
 	case GT, LTE:
 		if reflect.TypeOf(record) != reflect.TypeOf(v) {
@@ -157,6 +190,10 @@ func (field fieldMatcher) Match(record interface{}) (bool, error) {
 			return invert != (r <= v.(float32)), nil
 		case string:
 			return invert != (r <= v.(string)), nil
+		case time.Time:
+			return invert != !r.After(v.(time.Time)), nil
+		case time.Duration:
+			return invert != (r <= v.(time.Duration)), nil
 		}
 	case IN, NOT_IN:
 		if reflect.TypeOf(record) != reflect.TypeOf(v).Elem() {
@@ -205,7 +242,21 @@ func (field fieldMatcher) Match(record interface{}) (bool, error) {
 		case bool:
 			_, present := (field.fieldCache.(map[bool]int8))[r]
 			return invert != present, nil
+		case time.Duration:
+			_, present := (field.fieldCache.(map[time.Duration]int8))[r]
+			return invert != present, nil
+		case time.Time:
+			//Times in different locations can be equal, so they are compared one at a time
+			present := false
+			for _, t := range v.([]time.Time) {
+				present = present || r.Equal(t)
+			}
+			return invert != present, nil
 		}
+	case LIKE, NOT_LIKE, STARTS_WITH, NOT_STARTS_WITH, CONTAINS, NOT_CONTAINS, IEQ, NOT_IEQ:
+		return comparePattern(field.Op, record, v)
+	case BETWEEN, NOT_BETWEEN:
+		return compareBetween(field.Op, record, v)
 	case MATCH, NOT_MATCH:
 		if reflect.TypeOf(record) != reflect.TypeOf(v) {
 			return false, InvalidCompare(1)
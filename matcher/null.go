@@ -0,0 +1,123 @@
+package matcher
+
+import (
+	"reflect"
+	"strings"
+)
+
+/*
+This is the result of a comparison under the three valued logic that sql uses.  A comparison with NULL is neither true nor false, but unknown, and a record only matches when the whole expression is true
+
+    NOT unknown is unknown
+    false AND unknown is false, and true AND unknown is unknown
+    true OR unknown is true, and false OR unknown is unknown
+
+Only IS NULL and IS NOT NULL are ever true or false for a NULL.  Nil, a nil pointer and a sql.Null type that is not Valid are all NULL, and pointers and valid sql.Null types are compared by the value they hold
+*/
+type truth int8
+
+const (
+	isFalse truth = iota
+	isTrue
+	isUnknown
+)
+
+/*
+This is implemented by the matchers in this package, so that an unknown result is carried up through AND, OR and NOT instead of being taken as false too soon
+*/
+type ternaryMatcher interface {
+	matchTernary(record interface{}) (truth, error)
+}
+
+func truthOf(b bool) truth {
+	if b {
+		return isTrue
+	}
+	return isFalse
+}
+
+func (t truth) not() truth {
+	switch t {
+	case isTrue:
+		return isFalse
+	case isFalse:
+		return isTrue
+	}
+	return isUnknown
+}
+
+/*
+This evaluates any matcher under three valued logic.  Matchers from outside this package are true or false
+*/
+func matchTernary(m Matcher, record interface{}) (truth, error) {
+	if t, ok := m.(ternaryMatcher); ok {
+		return t.matchTernary(record)
+	}
+	result, err := m.Match(record)
+	return truthOf(result), err
+}
+
+/*
+This returns IS NULL
+*/
+func IsNull() Matcher {
+	return fieldMatcher{Op: IS_NULL}
+}
+
+/*
+This returns IS NOT NULL
+*/
+func IsNotNull() Matcher {
+	return fieldMatcher{Op: IS_NOT_NULL}
+}
+
+/*
+This is true for IS NULL and IS NOT NULL, which have no value to compare against
+*/
+func (op fieldOps) isNullCheck() bool {
+	return op == IS_NULL || op == IS_NOT_NULL
+}
+
+/*
+This returns the type of the value a field holds, and whether the field can hold NULL instead.  Pointers hold the type they point to, and the sql.Null types hold the type they wrap
+*/
+func NullableType(typ reflect.Type) (reflect.Type, bool) {
+	switch {
+	case typ.Kind() == reflect.Ptr:
+		return typ.Elem(), true
+	case isSqlNull(typ):
+		return typ.Field(0).Type, true
+	}
+	return typ, false
+}
+
+func isSqlNull(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ.PkgPath() != "database/sql" || !strings.HasPrefix(typ.Name(), "Null") {
+		return false
+	}
+	valid, present := typ.FieldByName("Valid")
+	return present && valid.Type.Kind() == reflect.Bool && typ.NumField() == 2
+}
+
+/*
+This returns the value held by a pointer or a sql.Null type, and whether it is NULL.  Other values are returned as they are
+*/
+func unwrapNull(value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	val := reflect.ValueOf(value)
+	switch {
+	case val.Kind() == reflect.Ptr:
+		if val.IsNil() {
+			return nil, true
+		}
+		return val.Elem().Interface(), false
+	case isSqlNull(val.Type()):
+		if !val.FieldByName("Valid").Bool() {
+			return nil, true
+		}
+		return val.Field(0).Interface(), false
+	}
+	return value, false
+}
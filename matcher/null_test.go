@@ -0,0 +1,143 @@
+package matcher
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+/*
+Pointers and the sql.Null types hold a value or NULL.  A comparison with NULL is unknown, so neither it nor its inverse matches, and only IS NULL and IS NOT NULL can test for it
+*/
+func ExampleIsNull() {
+	type Person struct {
+		Name    string
+		Manager *int
+		Nick    sql.NullString
+	}
+	p, _ := NewParser(map[string]interface{}{"Name": "", "Manager": (*int)(nil), "Nick": sql.NullString{}})
+	m, _ := p.Parse(`Manager IS NULL OR Nick = "Bob"`)
+	printed, _ := NewDefaultPrinter().Print(m)
+	fmt.Println(printed)
+	printed, _ = NewSqlitePrinter().Print(m)
+	fmt.Println(printed)
+
+	boss := 1
+	fmt.Println(m.Match(Person{Name: "Alice"}))
+	fmt.Println(m.Match(Person{Name: "Robert", Manager: &boss, Nick: sql.NullString{String: "Bob", Valid: true}}))
+	fmt.Println(m.Match(Person{Name: "Carol", Manager: &boss}))
+
+	//The nick of Alice is NULL, so it is neither Bob nor not Bob
+	bob, _ := p.Parse(`Nick = "Bob"`)
+	fmt.Println(Not(bob).Match(Person{Name: "Alice"}))
+
+	//Output:
+	//Manager IS NULL OR Nick = "Bob"
+	//Manager IS NULL OR Nick = 'Bob'
+	//true <nil>
+	//true <nil>
+	//false <nil>
+	//false <nil>
+}
+
+func TestNullMatch(t *testing.T) {
+	one := 1
+	type Boss struct {
+		Name string
+	}
+	type Person struct {
+		Boss *Boss
+		Size *int
+		Used int
+	}
+	//Not rewrites a struct matcher in place, so each case gets its own
+	refers := func() Matcher {
+		m := NewStructMatcher()
+		m.AddField("Used", Lt(m.Field("Size")))
+		return m
+	}
+	path := NewStructMatcher()
+	path.AddField("Boss.Name", IsNull())
+
+	cases := []struct {
+		m        Matcher
+		record   interface{}
+		expected bool
+	}{
+		{Eq(1), nil, false},
+		{Neq(1), nil, false},
+		{Not(Eq(1)), nil, false},
+		{NotIn([]int{1}), nil, false},
+		{IsNull(), nil, true},
+		{IsNotNull(), nil, false},
+		{Not(IsNull()), nil, false},
+		{Not(IsNotNull()), nil, true},
+		{Or(Eq(1), IsNull()), nil, true},
+		{And(Eq(1), IsNull()), nil, false},
+		{Not(And(Eq(1), Eq(2))), nil, false},
+		{Not(And(Eq(1), None())), nil, true},
+		{Not(Or(Eq(1), Any())), nil, false},
+		{Not(Or(Eq(1), None())), nil, false},
+		{Eq(1), &one, true},
+		{In([]int{1, 2}), &one, true},
+		{IsNotNull(), &one, true},
+		{IsNull(), (*int)(nil), true},
+		{Neq(1), (*int)(nil), false},
+		{Gt(int64(4)), sql.NullInt64{Int64: 5, Valid: true}, true},
+		{Eq(int64(0)), sql.NullInt64{}, false},
+		{IsNull(), sql.NullInt64{}, true},
+		{Match("^B"), sql.NullString{String: "Bob", Valid: true}, true},
+		{refers(), Person{Used: 1}, false},
+		{Not(refers()), Person{Used: 1}, false},
+		{refers(), Person{Used: 0, Size: &one}, true},
+		{path, Person{}, true},
+		{path, Person{Boss: &Boss{Name: "Alice"}}, false},
+	}
+	for _, c := range cases {
+		printed, _ := NewDefaultPrinter().Print(c.m)
+		result, err := c.m.Match(c.record)
+		if err != nil || result != c.expected {
+			t.Errorf("Expected %v to be %v for %#v, got %v %v", printed, c.expected, c.record, result, err)
+		}
+	}
+}
+
+func TestNullParse(t *testing.T) {
+	p, _ := NewParser(map[string]interface{}{"A": (*int)(nil), "B": sql.NullString{}})
+	valid := map[string]bool{
+		`A IS NULL`:                 true,
+		`A IS NOT NULL`:             false,
+		`NOT (A IS NULL)`:           false,
+		`A = 1 OR B IS NULL`:        true,
+		`A IS NULL AND B IS NULL`:   true,
+		`B IS NOT NULL OR A != 1`:   false,
+		`A IS NULL AND B = "Bacon"`: false,
+	}
+	for input, expected := range valid {
+		m, err := p.Parse(input)
+		if err != nil {
+			t.Errorf("Could not parse %v: %v", input, err)
+			continue
+		}
+		result, err := m.Match(map[string]interface{}{"A": nil, "B": sql.NullString{}})
+		if err != nil || result != expected {
+			t.Errorf("Expected %v to be %v, got %v %v", input, expected, result, err)
+		}
+		printed, _ := NewDefaultPrinter().Print(m)
+		if _, err := p.Parse(printed); err != nil {
+			t.Errorf("Could not parse %v back, as printed from %v: %v", printed, input, err)
+		}
+	}
+
+	for _, input := range []string{`A IS`, `A IS 5`, `A IS NOT`, `A IS NOT 5`, `A = NULL`} {
+		if _, err := p.Parse(input); err == nil {
+			t.Errorf("Expected %v not to parse", input)
+		}
+	}
+
+	notNull, _ := p.Parse(`A IS NOT NULL`)
+	failures, _ := Explain(notNull, map[string]interface{}{"A": nil})
+	if len(failures) != 1 || failures[0].String() != "A IS NOT NULL, but A is NULL" {
+		t.Errorf("Expected the NULL to be explained, got %v", failures)
+	}
+}
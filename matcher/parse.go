@@ -38,6 +38,36 @@ func (s MatchParseError) Error() string {
 
 type parseStruct struct {
 	Fields map[string]reflect.Kind
+	Types  map[string]reflect.Type
+}
+
+/*
+This promotes a value for the field.  Times and durations are told apart by their type, since their kinds are struct and int64
+*/
+func (service parseStruct) promote(field, value string) (interface{}, error) {
+	if typ := service.Types[field]; isTime(typ) {
+		return promoteToTime(typ, value)
+	}
+	return promoteToInterface(service.Fields[field], value)
+}
+
+/*
+This promotes every entry of an IN clause for the field
+*/
+func (service parseStruct) promoteAll(field string, values []string) (interface{}, error) {
+	typ := service.Types[field]
+	if !isTime(typ) {
+		return promoteToSlice(service.Fields[field], values)
+	}
+	output := reflect.MakeSlice(reflect.SliceOf(typ), 0, len(values))
+	for _, value := range values {
+		val, err := promoteToTime(typ, value)
+		if err != nil {
+			return nil, err
+		}
+		output = reflect.Append(output, reflect.ValueOf(val))
+	}
+	return output.Interface(), nil
 }
 
 func promoteToInterface(kind reflect.Kind, value string) (interface{}, error) {
@@ -88,6 +118,22 @@ func promoteToInterface(kind reflect.Kind, value string) (interface{}, error) {
 	return val, err
 }
 
+/*
+This promotes every entry of an IN clause, and returns them as a slice of the promoted type
+*/
+func promoteToSlice(kind reflect.Kind, values []string) (interface{}, error) {
+	zero, _ := promoteToInterface(kind, "0")
+	output := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(zero)), 0, len(values))
+	for _, value := range values {
+		val, err := promoteToInterface(kind, value)
+		if err != nil {
+			return nil, err
+		}
+		output = reflect.Append(output, reflect.ValueOf(val))
+	}
+	return output.Interface(), nil
+}
+
 func (service parseStruct) Parse(input string) (Matcher, error) {
 	tokens, err := tokenize(input)
 	if err != nil {
@@ -96,16 +142,28 @@ func (service parseStruct) Parse(input string) (Matcher, error) {
 	}
 	output := And()
 	Lookup := map[string]fieldOps{
-		"=":         EQ,
-		"!=":        NEQ,
-		"<":         LT,
-		"<=":        LTE,
-		">":         GT,
-		">=":        GTE,
-		"IN":        IN,
-		"NOT IN":    NOT_IN,
-		"MATCH":     MATCH,
-		"NOT MATCH": NOT_MATCH,
+		"=":               EQ,
+		"!=":              NEQ,
+		"<":               LT,
+		"<=":              LTE,
+		">":               GT,
+		">=":              GTE,
+		"IN":              IN,
+		"NOT IN":          NOT_IN,
+		"MATCH":           MATCH,
+		"NOT MATCH":       NOT_MATCH,
+		"IS NULL":         IS_NULL,
+		"IS NOT NULL":     IS_NOT_NULL,
+		"LIKE":            LIKE,
+		"NOT LIKE":        NOT_LIKE,
+		"BETWEEN":         BETWEEN,
+		"NOT BETWEEN":     NOT_BETWEEN,
+		"STARTS WITH":     STARTS_WITH,
+		"NOT STARTS WITH": NOT_STARTS_WITH,
+		"CONTAINS":        CONTAINS,
+		"NOT CONTAINS":    NOT_CONTAINS,
+		"IEQ":             IEQ,
+		"NOT IEQ":         NOT_IEQ,
 	}
 	iteration := 0
 
@@ -173,9 +231,20 @@ func (service parseStruct) Parse(input string) (Matcher, error) {
 		case op == "":
 			op = tokens[iteration]
 			cleanParse = UNFINISHED_MESSAGE
-		case op == "NOT":
+		case op == "NOT" || (op == "IS" && tokens[iteration] == "NOT") || ((op == "STARTS" || op == "NOT STARTS") && tokens[iteration] == "WITH"):
 			op += " " + tokens[iteration]
 			cleanParse = UNFINISHED_MESSAGE
+		case (op == "IS" || op == "IS NOT") && tokens[iteration] == "NULL":
+			realOp, _ := Lookup[op+" NULL"]
+			if field == "_" {
+				output = conjoin(output, And(fieldMatcher{Op: realOp}))
+			} else {
+				temp := NewStructMatcher()
+				temp.AddField(field, fieldMatcher{Op: realOp})
+				output = conjoin(output, And(temp))
+			}
+			field, op, value = "", "", ""
+			cleanParse = VALID
 		case op == "IN" || op == "NOT IN":
 			localIteration := iteration + 1
 			vals := make([]string, 0)
@@ -189,16 +258,42 @@ func (service parseStruct) Parse(input string) (Matcher, error) {
 			iteration = localIteration
 			step := And()
 			realOp, _ := Lookup[op]
+			list, promotionError := service.promoteAll(field, vals)
+			if promotionError != nil {
+				cleanParse = PROMOTION_ERROR
+				return returnF(fmt.Sprintf("Could not promote field %v to kind %v for values %v", field, service.Fields[field], vals))
+			}
 			if field == "_" {
-				step = And(step, fieldMatcher{Op: realOp, Value: value})
+				step = And(step, fieldMatcher{Op: realOp, Value: list})
 			} else {
 				temp := NewStructMatcher()
-				temp.AddField(field, fieldMatcher{Op: realOp, Value: value})
+				temp.AddField(field, fieldMatcher{Op: realOp, Value: list})
 				step = And(step, temp)
 			}
 			output = conjoin(output, step)
 			field, op, value = "", "", ""
 			cleanParse = VALID
+		case op == "BETWEEN" || op == "NOT BETWEEN":
+			if iteration+2 >= len(tokens) || tokens[iteration+1] != "AND" {
+				return returnF("BETWEEN needs two values joined by AND")
+			}
+			vals := []string{tokens[iteration], tokens[iteration+2]}
+			iteration += 2
+			realOp, _ := Lookup[op]
+			list, promotionError := service.promoteAll(field, vals)
+			if promotionError != nil {
+				cleanParse = PROMOTION_ERROR
+				return returnF(fmt.Sprintf("Could not promote field %v to kind %v for values %v", field, service.Fields[field], vals))
+			}
+			if field == "_" {
+				output = conjoin(output, And(fieldMatcher{Op: realOp, Value: list}))
+			} else {
+				temp := NewStructMatcher()
+				temp.AddField(field, fieldMatcher{Op: realOp, Value: list})
+				output = conjoin(output, And(temp))
+			}
+			field, op, value = "", "", ""
+			cleanParse = VALID
 		default:
 			value = tokens[iteration]
 			step := And()
@@ -207,10 +302,17 @@ func (service parseStruct) Parse(input string) (Matcher, error) {
 				cleanParse = INVALID_OPERATION
 				return returnF("Operation type is not supported: " + op)
 			}
+			if realOp.isString() && service.Fields[field] != reflect.String {
+				cleanParse = INVALID_OPERATION
+				return returnF(fmt.Sprintf("Operation %v needs a string field, but %v is %v", op, field, service.Fields[field]))
+			}
 
 			kind := service.Fields[field]
-			val, promotionError := promoteToInterface(kind, value)
+			val, promotionError := service.promote(field, value)
 			valKind, symbolHit := service.Fields[value]
+			if relative, ok := parseRelative(value); ok && service.Types[field] == timeType && !symbolHit {
+				val, promotionError = relative, nil
+			}
 			if promotionError != nil {
 				if !symbolHit {
 					cleanParse = PROMOTION_ERROR
@@ -252,7 +354,9 @@ func tokenize(message string) ([]string, error) {
 	whitespace, _ := regexp.Compile("^[\\s,]+")
 	lParen, _ := regexp.Compile("^\\(")
 	rParen, _ := regexp.Compile("^\\)")
-	symbol, _ := regexp.Compile("^[a-zA-Z_]\\w*")
+	symbol, _ := regexp.Compile("^[a-zA-Z_]\\w*(\\.[a-zA-Z_]\\w*)*")
+	timestamp, _ := regexp.Compile("^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})")
+	duration, _ := regexp.Compile("^-?" + durationPattern)
 	number, _ := regexp.Compile("^-?[0-9]+(\\.[0-9]+)?")
 	operators, _ := regexp.Compile("^[!=<>]+")
 	quote, _ := regexp.Compile("^\"(?:\\\\?.)*?\"")
@@ -266,9 +370,20 @@ func tokenize(message string) ([]string, error) {
 		case rParen.MatchString(message):
 			output = append(output, rParen.FindString(message))
 			message = rParen.ReplaceAllString(message, "")
+		case len(relativePattern.FindString(message)) >= len(symbol.FindString(message)) && relativePattern.MatchString(message):
+			//A relative time is one token, such as now-5m, unless it is the start of a longer symbol
+			relative := relativePattern.FindString(message)
+			output = append(output, strings.Join(strings.Fields(relative), ""))
+			message = message[len(relative):]
 		case symbol.MatchString(message):
 			output = append(output, symbol.FindString(message))
 			message = symbol.ReplaceAllString(message, "")
+		case timestamp.MatchString(message):
+			output = append(output, timestamp.FindString(message))
+			message = timestamp.ReplaceAllString(message, "")
+		case duration.MatchString(message):
+			output = append(output, duration.FindString(message))
+			message = duration.ReplaceAllString(message, "")
 		case number.MatchString(message):
 			output = append(output, number.FindString(message))
 			message = number.ReplaceAllString(message, "")
@@ -289,19 +404,25 @@ func tokenize(message string) ([]string, error) {
 This returns a new parser object that uses the context given.  This context will determine what the symbols type is.  (Is A a string, and int, a float?).  The context itself can be many different types, as a convenience to the developer
 
 	map[string]reflect.Kind - This will bind the symbols to use the Kind specified.  It will allow a struct matcher to be returned
-	map[string]interface{} - This will bind the symbols to use the Kind of the underlying interface.  It will allow a struct matcher to be returned.
+	map[string]reflect.Type - This will bind the symbols to use the Type specified, which is needed for times.  It will allow a struct matcher to be returned
+	map[string]interface{} - This will bind the symbols to use the Kind of the underlying interface.  It will allow a struct matcher to be returned.  Nested maps bind dotted paths, so {"Device": {"Name": ""}} binds Device.Name
 	reflect.Kind - This will bind the symbol "_" to the kind passed in.  It will allow a field matcher to be returned
 	interface{} - This will bind the symbol "_" to the kind of the underlying interface.  It will allow a field matcher to be returned
 
-This currently only works with kinds in the string, bool, flaot, int and uint families.  You may use the DefaultParser function in the goflect package to get a context based on structs
+Symbols in a map[string]reflect.Kind may also be dotted paths, which the struct matcher follows into nested structs and maps.  This currently only works with kinds in the string, bool, flaot, int and uint families, and with time.Time and time.Duration when the context has their types.  Times are written in RFC3339, such as 2020-01-02T15:04:05Z, or relative to when the matcher runs, such as now - 5m.  Durations are written as time.ParseDuration reads them, such as 1h30m.  You may use the DefaultParser function in the goflect package to get a context based on structs
 
 Please read the documentation of go's reflect package to understand how reflect.Kind works
 */
 func NewParser(context interface{}) (Parser, error) {
 	localContext := make(map[string]reflect.Kind)
+	types := make(map[string]reflect.Type)
 	switch c := context.(type) {
 	case map[string]reflect.Kind:
 		localContext = c
+	case map[string]reflect.Type:
+		for name, typ := range c {
+			addType(localContext, types, name, typ)
+		}
 	case reflect.Kind:
 		switch c {
 		case reflect.Bool,
@@ -323,12 +444,14 @@ func NewParser(context interface{}) (Parser, error) {
 			return nil, MatchParseError{Code: INVALID_CONTEXT, Message: ("Got kind " + c.String())}
 		}
 	case map[string]interface{}:
-		for name, value := range c {
-			typ := reflect.TypeOf(value)
-			localContext[name] = typ.Kind()
-		}
+		addContext(localContext, types, "", c)
 	default:
 		typ := reflect.TypeOf(c)
+		if isTime(typ) {
+			localContext["_"] = typ.Kind()
+			types["_"] = typ
+			break
+		}
 		switch typ.Kind() {
 		case reflect.Bool,
 			reflect.String,
@@ -350,5 +473,27 @@ func NewParser(context interface{}) (Parser, error) {
 		}
 	}
 
-	return parseStruct{Fields: localContext}, nil
+	return parseStruct{Fields: localContext, Types: types}, nil
+}
+
+/*
+This binds every entry of the map to its kind and type, and the entries of nested maps to their dotted paths
+*/
+func addContext(context map[string]reflect.Kind, types map[string]reflect.Type, prefix string, values map[string]interface{}) {
+	for name, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			addContext(context, types, prefix+name+".", nested)
+			continue
+		}
+		addType(context, types, prefix+name, reflect.TypeOf(value))
+	}
+}
+
+/*
+This binds a field to its type.  Fields that can be NULL are bound to the type they hold, so that they are compared to values of that type
+*/
+func addType(context map[string]reflect.Kind, types map[string]reflect.Type, name string, typ reflect.Type) {
+	typ, _ = NullableType(typ)
+	context[name] = typ.Kind()
+	types[name] = typ
 }
@@ -163,6 +163,11 @@ func TestParsing(t *testing.T) {
 			return T, F, E
 		}
 	}
+	//Every comparison with NULL is unknown, which does not match
+	isNull := func(matcher Matcher) funcSig {
+		_, F, _ := withMatcher(matcher)
+		return F
+	}
 
 	comparisonWorkout := func(parser Parser, smaller, bigger, nonsense interface{}) {
 		matcher, _ := parser.Parse("")
@@ -178,7 +183,7 @@ func TestParsing(t *testing.T) {
 			A(fmt.Sprintf("Matching %v,%v for %v", smaller, smaller, op), smaller)
 			B(fmt.Sprintf("Matching %v,%v for %v", smaller, bigger, op), bigger)
 			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nonsense, op), nonsense)
-			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
+			isNull(matcher)(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
 		}
 	}
 
@@ -234,8 +239,8 @@ func TestParsing(t *testing.T) {
 			A(fmt.Sprintf("Matching %v,%v for %v", smaller, smaller, op), buildMap(smaller))
 			B(fmt.Sprintf("Matching %v,%v for %v", smaller, bigger, op), buildMap(bigger))
 			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nonsense, op), buildMap(nonsense))
-			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), buildMap(nil))
-			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
+			isNull(matcher)(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), buildMap(nil))
+			isNull(matcher)(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
 		}
 	}
 
@@ -281,7 +286,7 @@ func TestParsing(t *testing.T) {
 			A(fmt.Sprintf("Matching %v,%v for %v", smaller, smaller, op), smaller)
 			B(fmt.Sprintf("Matching %v,%v for %v", smaller, bigger, op), bigger)
 			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nonsense, op), nonsense)
-			C(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
+			isNull(matcher)(fmt.Sprintf("Matching %v,%v for %v", smaller, nil, op), nil)
 		}
 	}
 
@@ -312,6 +317,34 @@ func TestParsing(t *testing.T) {
 
 }
 
+func TestParseIn(t *testing.T) {
+	withMatcher := withMatcherFactory(t)
+
+	p, _ := NewParser(map[string]reflect.Kind{"A": reflect.Int64, "B": reflect.String, "_": reflect.Float32})
+	m, err := p.Parse("A IN (1, 2, 3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	T, F, _ := withMatcher(m)
+	T("2 is in the list", map[string]interface{}{"A": int64(2)})
+	F("4 is not in the list", map[string]interface{}{"A": int64(4)})
+
+	m, _ = p.Parse("B NOT IN (\"x\" \"y\")")
+	T, F, _ = withMatcher(m)
+	T("z is not in the list", map[string]interface{}{"B": "z"})
+	F("x is in the list", map[string]interface{}{"B": "x"})
+
+	m, _ = p.Parse("_ IN (0.5, 1.5)")
+	T, F, _ = withMatcher(m)
+	T("0.5 is in the list", float32(0.5))
+	F("1 is not in the list", float32(1))
+
+	_, err = p.Parse("A IN (1, \"x\")")
+	if perr, ok := err.(MatchParseError); !ok || perr.Code != PROMOTION_ERROR {
+		t.Errorf("Expected a promotion error, got %v", err)
+	}
+}
+
 func TestTokenize(t *testing.T) {
 	expectedLen := func(s string, length int, code parseErrors) {
 		tokens, e := tokenize(s)
@@ -633,3 +666,33 @@ func ExampleParser_5() {
 	//Expression '_ != T' matches 'false'
 	//Expression '_ != F' does not match 'false'
 }
+
+/*
+Dotted paths reach into nested maps and structs.  Nested maps in the context bind the paths of their entries
+*/
+func ExampleParser_paths() {
+	p, _ := NewParser(map[string]interface{}{
+		"Name":     "",
+		"Location": map[string]interface{}{"Name": "", "Floor": 0},
+	})
+	match, err := p.Parse(`Location.Floor > 2 AND Location.Name != Name`)
+	fmt.Println(err)
+
+	printed, _ := NewDefaultPrinter().Print(match)
+	fmt.Println(printed)
+
+	result, _ := match.Match(map[string]interface{}{
+		"Name":     "Server",
+		"Location": map[string]interface{}{"Name": "Roof", "Floor": 9},
+	})
+	fmt.Println(result)
+
+	_, err = p.Parse(`Location.Room = 1`)
+	fmt.Println(err)
+
+	//Output:
+	//<nil>
+	//Location.Floor > 2 AND Location.Name != Name
+	//true
+	//Unknown Field provided: Location.Room
+}
@@ -0,0 +1,130 @@
+package matcher
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+/*
+This is the escape character used when a prefix or a substring is written as a LIKE pattern.  It is not a backslash, since MySQL reads a backslash in a string literal as an escape of its own
+*/
+const likeEscape = "!"
+
+/*
+This returns the bounds of a BETWEEN as a slice of their type, so that they print, bind and encode like the values of an IN.  Bounds of different types are kept, and fail when they are compared
+*/
+func bounds(low, high interface{}) interface{} {
+	if low == nil || reflect.TypeOf(low) != reflect.TypeOf(high) {
+		return []interface{}{low, high}
+	}
+	output := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(low)), 0, 2)
+	output = reflect.Append(output, reflect.ValueOf(low), reflect.ValueOf(high))
+	return output.Interface()
+}
+
+/*
+This is true for the ops that compare strings by a pattern, which print as LIKE in sql
+*/
+func (op fieldOps) isPattern() bool {
+	switch op {
+	case LIKE, NOT_LIKE, STARTS_WITH, NOT_STARTS_WITH, CONTAINS, NOT_CONTAINS:
+		return true
+	}
+	return false
+}
+
+/*
+This is true for the ops that only compare strings, and print differently from the other comparisons in sql
+*/
+func (op fieldOps) isString() bool {
+	return op.isPattern() || op == IEQ || op == NOT_IEQ
+}
+
+/*
+This converts a LIKE pattern to a regular expression.  The pattern must match the whole string.  It is matched against a string folded by asciiLower, so it is folded the same way, and ASCII letters match either case
+*/
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = asciiLower(pattern)
+	output := "(?s)^"
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			output += ".*"
+		case '_':
+			output += "."
+		default:
+			output += regexp.QuoteMeta(string(c))
+		}
+	}
+	return regexp.Compile(output + "$")
+}
+
+/*
+This lowers the case of the ASCII letters only, which is all that LIKE and LOWER fold in sqlite.  Other letters keep their case, so É and é differ, as they do there.  PostgreSQL and MySQL fold them too, depending on the collation
+*/
+func asciiLower(value string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'A' && c <= 'Z' {
+			return c + 'a' - 'A'
+		}
+		return c
+	}, value)
+}
+
+/*
+This writes a prefix or a substring as a LIKE pattern, escaping the characters LIKE treats specially
+*/
+func likeLiteral(op fieldOps, value string) string {
+	replacer := strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+	value = replacer.Replace(value) + "%"
+	if op == CONTAINS || op == NOT_CONTAINS {
+		value = "%" + value
+	}
+	return value
+}
+
+/*
+This compares strings for the pattern ops and case insensitive equality.  Only ASCII letters are folded, as sqlite does
+*/
+func comparePattern(op fieldOps, record, v interface{}) (bool, error) {
+	r, ok := record.(string)
+	pattern, valid := v.(string)
+	if !ok || !valid {
+		return false, InvalidCompare(1)
+	}
+	switch op {
+	case LIKE, NOT_LIKE:
+		exp, err := likeRegexp(pattern)
+		if err != nil {
+			return false, InvalidCompare(1)
+		}
+		return (op == NOT_LIKE) != exp.MatchString(asciiLower(r)), nil
+	case STARTS_WITH, NOT_STARTS_WITH:
+		return (op == NOT_STARTS_WITH) != strings.HasPrefix(asciiLower(r), asciiLower(pattern)), nil
+	case CONTAINS, NOT_CONTAINS:
+		return (op == NOT_CONTAINS) != strings.Contains(asciiLower(r), asciiLower(pattern)), nil
+	case IEQ, NOT_IEQ:
+		return (op == NOT_IEQ) != (asciiLower(r) == asciiLower(pattern)), nil
+	}
+	return false, InvalidCompare(1)
+}
+
+/*
+This tests that the record is within the bounds of a BETWEEN, including both of them
+*/
+func compareBetween(op fieldOps, record, v interface{}) (bool, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice || val.Len() != 2 {
+		return false, InvalidCompare(1)
+	}
+	above, err := fieldMatcher{Op: GTE}.compare(record, val.Index(0).Interface())
+	if err != nil {
+		return false, err
+	}
+	below, err := fieldMatcher{Op: LTE}.compare(record, val.Index(1).Interface())
+	if err != nil {
+		return false, err
+	}
+	return (op == NOT_BETWEEN) != (above && below), nil
+}
@@ -0,0 +1,180 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+/*
+The string operators print as LIKE in sql, so they work without a regular expression extension.  A prefix or a substring is escaped, so that % and _ in it are matched literally
+*/
+func ExampleLike() {
+	p, _ := NewParser(map[string]reflect.Kind{"Name": reflect.String, "Port": reflect.Int})
+	m, _ := p.Parse(`Name LIKE "web%" OR Name STARTS WITH "db_" OR Name IEQ "Cache" AND Port BETWEEN 8000 AND 8999`)
+	printed, _ := NewDefaultPrinter().Print(m)
+	fmt.Println(printed)
+	printed, params, _ := NewSqliteParamPrinter().PrintParams(m)
+	fmt.Println(printed, params)
+
+	fmt.Println(m.Match(map[string]interface{}{"Name": "WEB-1", "Port": 80}))
+	fmt.Println(m.Match(map[string]interface{}{"Name": "dbx", "Port": 80}))
+	fmt.Println(m.Match(map[string]interface{}{"Name": "cache", "Port": 8080}))
+
+	//Output:
+	//(Name LIKE "web%" OR Name STARTS WITH "db_" OR Name IEQ "Cache") AND Port BETWEEN 8000 AND 8999
+	//(Name LIKE ? OR Name LIKE ? ESCAPE '!' OR LOWER(Name) = LOWER(?)) AND Port BETWEEN ? AND ? [web% db!_% Cache 8000 8999]
+	//false <nil>
+	//false <nil>
+	//true <nil>
+}
+
+func ExampleBetween() {
+	m := NewStructMatcher()
+	m.AddField("Port", Between(1024, 49151))
+	printed, _ := NewSqlitePrinter().Print(m)
+	fmt.Println(printed)
+	fmt.Println(m.Match(map[string]interface{}{"Port": 1024}))
+	fmt.Println(m.Match(map[string]interface{}{"Port": 80}))
+
+	printed, _ = NewDefaultPrinter().Print(Not(m))
+	fmt.Println(printed)
+
+	//Output:
+	//Port BETWEEN 1024 AND 49151
+	//true <nil>
+	//false <nil>
+	//Port NOT BETWEEN 1024 AND 49151
+}
+
+func TestPatternMatch(t *testing.T) {
+	base := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		m        Matcher
+		record   interface{}
+		expected bool
+	}{
+		{Like("a%c"), "abbc", true},
+		{Like("a%c"), "ABC", true},
+		{Like("a%c"), "abcd", false},
+		{Like("a_c"), "abc", true},
+		{Like("a_c"), "ac", false},
+		{Like("a.c"), "abc", false},
+		{Like("a\nb%"), "A\nBC", true},
+		{NotLike("a%"), "bac", true},
+		{StartsWith("Ab"), "abc", true},
+		{StartsWith("a%"), "abc", false},
+		{StartsWith("a%"), "a%c", true},
+		{NotStartsWith("b"), "abc", true},
+		{Contains("B"), "abc", true},
+		{Contains("_"), "abc", false},
+		{NotContains("d"), "abc", true},
+		{Ieq("ABC"), "abc", true},
+		{Ieq("É"), "é", false},
+		{Like("é%"), "ÉCOLE", false},
+		{Contains("ß"), "STRASSE", false},
+		{StartsWith("Ça"), "ça va", false},
+		{StartsWith("Ça"), "ÇA VA", true},
+		{Ieq("ABC"), "abcd", false},
+		{NotIeq("ABC"), "abc", false},
+		{Between(1, 3), 1, true},
+		{Between(1, 3), 3, true},
+		{Between(1, 3), 4, false},
+		{Between("b", "d"), "c", true},
+		{NotBetween(1.5, 2.5), 3.0, true},
+		{Between(base, base.Add(time.Hour)), base.Add(time.Minute), true},
+		{Between(time.Second, time.Minute), time.Hour, false},
+		{Not(Like("a%")), "abc", false},
+		{Not(StartsWith("a")), "abc", false},
+		{Not(Contains("b")), "abc", false},
+		{Not(Ieq("ABC")), "abc", false},
+		{Not(Between(1, 3)), 2, false},
+		{Not(NotBetween(1, 3)), 2, true},
+	}
+	for _, c := range cases {
+		printed, _ := NewDefaultPrinter().Print(c.m)
+		result, err := c.m.Match(c.record)
+		if err != nil || result != c.expected {
+			t.Errorf("Expected %v to be %v for %#v, got %v %v", printed, c.expected, c.record, result, err)
+		}
+	}
+
+	for _, m := range []Matcher{Like("a"), StartsWith("a"), Ieq("a"), Between(1, int64(3)), Between(1, 3)} {
+		if _, err := m.Match(2.5); err == nil {
+			t.Errorf("Expected an error matching %#v against a float", m)
+		}
+	}
+}
+
+func TestPatternParse(t *testing.T) {
+	p, _ := NewParser(map[string]interface{}{"S": "", "I": 0, "T": time.Time{}})
+	valid := map[string]bool{
+		`S LIKE "b%"`:                     true,
+		`S NOT LIKE "b%"`:                 false,
+		`S STARTS WITH "BA"`:              true,
+		`S NOT STARTS WITH "ba"`:          false,
+		`S CONTAINS "CO"`:                 true,
+		`S NOT CONTAINS "x"`:              true,
+		`S IEQ "BACON"`:                   true,
+		`S NOT IEQ "BACON"`:               false,
+		`I BETWEEN 1 AND 5`:               true,
+		`I NOT BETWEEN 1 AND 5`:           false,
+		`I BETWEEN 1 AND 2 AND S IEQ "x"`: false,
+		`I BETWEEN 5 AND 9 OR S LIKE "%"`: true,
+		`NOT (I BETWEEN 1 AND 5)`:         false,
+		`T BETWEEN 2020-01-01T00:00:00Z AND "2020-12-31T00:00:00Z"`: true,
+	}
+	record := map[string]interface{}{"S": "bacon", "I": 3, "T": time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)}
+	for input, expected := range valid {
+		m, err := p.Parse(input)
+		if err != nil {
+			t.Errorf("Could not parse %v: %v", input, err)
+			continue
+		}
+		result, err := m.Match(record)
+		if err != nil || result != expected {
+			t.Errorf("Expected %v to be %v, got %v %v", input, expected, result, err)
+		}
+		printed, _ := NewDefaultPrinter().Print(m)
+		again, err := p.Parse(printed)
+		if err != nil {
+			t.Errorf("Could not parse %v back, as printed from %v: %v", printed, input, err)
+			continue
+		}
+		if result, _ := again.Match(record); result != expected {
+			t.Errorf("Expected %v to be %v once printed and parsed, from %v", printed, expected, input)
+		}
+	}
+
+	for _, input := range []string{`I LIKE "1%"`, `S STARTS "a"`, `S STARTS WITH`, `I BETWEEN 1`, `I BETWEEN 1 OR 2`, `I BETWEEN 1 AND "a"`, `T IEQ "a"`} {
+		if _, err := p.Parse(input); err == nil {
+			t.Errorf("Expected %v not to parse", input)
+		}
+	}
+}
+
+func TestPatternPrint(t *testing.T) {
+	cases := []struct {
+		m        Matcher
+		expected string
+	}{
+		{StartsWith("it"), `_ LIKE 'it%' ESCAPE '!'`},
+		{NotContains("5!%"), `_ NOT LIKE '%5!!!%%' ESCAPE '!'`},
+		{NotIeq("a"), `LOWER(_) != LOWER('a')`},
+		{Between("a", "b"), `_ BETWEEN 'a' AND 'b'`},
+		{NotBetween(int8(1), int8(2)), `_ NOT BETWEEN 1 AND 2`},
+	}
+	for _, c := range cases {
+		printed, err := NewSqlitePrinter().Print(c.m)
+		if err != nil || printed != c.expected {
+			t.Errorf("Expected %v, got %v %v", c.expected, printed, err)
+		}
+	}
+
+	reference := NewStructMatcher()
+	reference.AddField("A", fieldMatcher{Op: STARTS_WITH, Value: reference.Field("B")})
+	if _, _, err := NewSqliteParamPrinter().PrintParams(reference); err == nil {
+		t.Errorf("Expected a prefix held in another column to be an error")
+	}
+}
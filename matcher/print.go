@@ -2,9 +2,11 @@ package matcher
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 /*
@@ -14,6 +16,37 @@ func NewSqlitePrinter() Printer {
 	return sqlitePrinter{}
 }
 
+/*
+This returns a printer that is designed to print the matcher as a where clause for sqlite, using ? placeholders for every value.  The values are returned in order by PrintParams, and must be passed to Exec or Query alongside the statement.  This is the printer to use with user supplied input
+*/
+func NewSqliteParamPrinter() ParamPrinter {
+	return sqlitePrinter{params: new([]interface{})}
+}
+
+/*
+This returns a param printer that also renames fields to columns as it prints them.  The map is keyed by field name, and its values are printed verbatim, so they should already be quoted or qualified as needed
+*/
+func NewSqliteColumnPrinter(columns map[string]string) ParamPrinter {
+	return sqlitePrinter{params: new([]interface{}), columns: columns}
+}
+
+/*
+This is the part of a sql dialect that matters when printing a where clause.  The record services provide implementations for the databases they support.  LikeOperator was added with the pattern ops, so a dialect written outside this module must add it before it compiles again
+*/
+type Dialect interface {
+	QuoteIdentifier(name string) string
+	RegexOperator(negate bool) string
+	//This is the operator for LIKE, which must ignore the case of letters as sqlite does.  Sqlite only folds the ASCII letters, and the matchers do the same
+	LikeOperator(negate bool) string
+}
+
+/*
+This returns a param printer for another flavour of sql.  It works like the sqlite column printer, except that fields without an entry in the column map are quoted by the dialect, MATCH and LIKE use the dialect's operators for them, and Any and None print as comparisons so that every database accepts them.  Placeholders are always ?, so statements for databases with numbered placeholders must be rebound once they are complete
+*/
+func NewDialectPrinter(dialect Dialect, columns map[string]string) ParamPrinter {
+	return sqlitePrinter{params: new([]interface{}), columns: columns, dialect: dialect}
+}
+
 /*
 This returns a pretty printer that can render the in memory expressions.  It is designed to be compatible with the output of the parser for built in exressions.  Some yields or matcher lambdas will not make the round trip properly
 */
@@ -26,7 +59,47 @@ type defaultPrinter struct {
 }
 
 type sqlitePrinter struct {
-	v string
+	v       string
+	params  *[]interface{}
+	columns map[string]string
+	dialect Dialect
+}
+
+/*
+This returns the column a field is stored in.  Fields without an entry in the column map are printed as is, and a dialect quotes each part of a dotted path, so that Table.Column names a column of a joined table
+*/
+func (p sqlitePrinter) column(name string) string {
+	if column, present := p.columns[name]; present {
+		return column
+	}
+	if p.dialect != nil {
+		parts := strings.Split(name, ".")
+		for i, part := range parts {
+			parts[i] = p.dialect.QuoteIdentifier(part)
+		}
+		return strings.Join(parts, ".")
+	}
+	return name
+}
+
+/*
+This returns the sql for the operator
+*/
+func (p sqlitePrinter) operator(op fieldOps) string {
+	if p.dialect != nil && (op == MATCH || op == NOT_MATCH) {
+		return p.dialect.RegexOperator(op == NOT_MATCH)
+	}
+	if op.isPattern() {
+		negate := op == NOT_LIKE || op == NOT_STARTS_WITH || op == NOT_CONTAINS
+		if p.dialect != nil {
+			return p.dialect.LikeOperator(negate)
+		}
+		if negate {
+			return "NOT LIKE"
+		}
+		return "LIKE"
+	}
+	return op.String()
 }
 
 func printAnd(p Printer, r andMatch) (string, error) {
@@ -114,6 +187,35 @@ func printStruct(factory func(name string) Printer, r *structMatcher) (string, e
 	return strings.Join(output, " AND "), nil
 }
 
+/*
+This renders a value the way the default printer does, with strings quoted, lists in brackets and fields by name.  Times are RFC3339, and durations and relative times are written as the parser reads them
+*/
+func printValue(value interface{}) string {
+	switch val := value.(type) {
+	case []string:
+		entries := make([]string, 0)
+		for _, v := range val {
+			entries = append(entries, q(v))
+		}
+		return "[" + strings.Join(entries, " ") + "]"
+	case string:
+		return q(val)
+	case fieldYielder:
+		return val.Name
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case []time.Time, []time.Duration:
+		list := reflect.ValueOf(val)
+		entries := make([]string, 0)
+		for i := 0; i < list.Len(); i++ {
+			entries = append(entries, printValue(list.Index(i).Interface()))
+		}
+		return "[" + strings.Join(entries, " ") + "]"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
 func q(name string) string {
 	return "\"" + name + "\""
 }
@@ -142,6 +244,30 @@ func (token fieldOps) String() string {
 		output += "MATCH"
 	case NOT_MATCH:
 		output += "NOT MATCH"
+	case IS_NULL:
+		output += "IS NULL"
+	case IS_NOT_NULL:
+		output += "IS NOT NULL"
+	case LIKE:
+		output += "LIKE"
+	case NOT_LIKE:
+		output += "NOT LIKE"
+	case BETWEEN:
+		output += "BETWEEN"
+	case NOT_BETWEEN:
+		output += "NOT BETWEEN"
+	case STARTS_WITH:
+		output += "STARTS WITH"
+	case NOT_STARTS_WITH:
+		output += "NOT STARTS WITH"
+	case CONTAINS:
+		output += "CONTAINS"
+	case NOT_CONTAINS:
+		output += "NOT CONTAINS"
+	case IEQ:
+		output += "IEQ"
+	case NOT_IEQ:
+		output += "NOT IEQ"
 	}
 	return output
 }
@@ -165,23 +291,16 @@ func (p defaultPrinter) Print(m Matcher) (string, error) {
 			output += p.v
 		}
 		output += " " + r.Op.String()
-		switch val := r.Value.(type) {
-		case []string:
-			output += " ["
-			entries := make([]string, 0)
-			for _, v := range val {
-				entries = append(entries, q(v))
-			}
-			output += strings.Join(entries, " ")
-			output += "]"
+		if r.Op.isNullCheck() {
 			return output, nil
-		case string:
-			return output + " " + q(val), nil
-		case fieldYielder:
-			return output + " " + val.Name, nil
-		default:
-			return output + " " + fmt.Sprint(r.Value), nil
 		}
+		if r.Op == BETWEEN || r.Op == NOT_BETWEEN {
+			val := reflect.ValueOf(r.Value)
+			if val.Kind() == reflect.Slice && val.Len() == 2 {
+				return output + " " + printValue(val.Index(0).Interface()) + " AND " + printValue(val.Index(1).Interface()), nil
+			}
+		}
+		return output + " " + printValue(r.Value), nil
 	case invertMatch:
 		return printInvert(p, r)
 	case noneMatch:
@@ -204,15 +323,29 @@ func (p sqlitePrinter) Print(m Matcher) (string, error) {
 	case orMatch:
 		return printOr(p, r)
 	case *structMatcher:
-		return printStruct(func(name string) Printer { return sqlitePrinter{v: name} }, r)
+		return printStruct(func(name string) Printer {
+			return sqlitePrinter{v: name, params: p.params, columns: p.columns, dialect: p.dialect}
+		}, r)
 	case fieldMatcher:
 		output := ""
 		if p.v == "" {
 			output += "_"
 		} else {
-			output += p.v
+			output += p.column(p.v)
+		}
+		if r.Op.isString() {
+			return p.printString(output, r)
+		}
+		if r.Op == BETWEEN || r.Op == NOT_BETWEEN {
+			return p.printBetween(output, r)
+		}
+		output += " " + p.operator(r.Op)
+		if r.Op.isNullCheck() {
+			return output, nil
+		}
+		if p.params != nil {
+			return p.bind(output, r)
 		}
-		output += " " + r.Op.String()
 		makeInish := func(entries []string) string {
 			output += " ("
 			output += strings.Join(entries, ", ")
@@ -289,18 +422,157 @@ func (p sqlitePrinter) Print(m Matcher) (string, error) {
 		case string:
 			return output + " " + "'" + val + "'", nil
 		case fieldYielder:
-			return output + " " + val.Name, nil
+			return output + " " + p.column(val.Name), nil
+		case []time.Time, []time.Duration:
+			list := reflect.ValueOf(val)
+			for i := 0; i < list.Len(); i++ {
+				entries = append(entries, sqlLiteral(list.Index(i).Interface()))
+			}
+			return makeInish(entries), nil
+		case Yielder:
+			result, err := val.Yield()
+			if err != nil {
+				return "", err
+			}
+			return output + " " + sqlLiteral(result), nil
 		default:
-			return output + " " + fmt.Sprint(r.Value), nil
+			return output + " " + sqlLiteral(r.Value), nil
 		}
 	case invertMatch:
 		return printInvert(p, r)
 	case noneMatch:
+		if p.dialect != nil {
+			return "1 = 0", nil
+		}
 		return "0", nil
 	case anyMatch:
+		if p.dialect != nil {
+			return "1 = 1", nil
+		}
 		return "1", nil
 	case errorMatch:
 		return "", InvalidCompare(0)
 	}
 	return "", nil
 }
+
+/*
+This prints the matcher with ? placeholders, and returns the values to bind in the order they appear in the statement
+*/
+func (p sqlitePrinter) PrintParams(m Matcher) (string, []interface{}, error) {
+	local := sqlitePrinter{v: p.v, params: new([]interface{}), columns: p.columns, dialect: p.dialect}
+	result, err := local.Print(m)
+	if err != nil {
+		return "", nil, err
+	}
+	return result, *local.params, nil
+}
+
+func (p sqlitePrinter) bind(output string, r fieldMatcher) (string, error) {
+	v := r.Value
+	switch y := v.(type) {
+	case fieldYielder:
+		return output + " " + p.column(y.Name), nil
+	case Yielder:
+		result, err := y.Yield()
+		if err != nil {
+			return "", err
+		}
+		v = result
+	}
+	switch r.Op {
+	case IN, NOT_IN:
+		val := reflect.ValueOf(v)
+		if val.Kind() != reflect.Array && val.Kind() != reflect.Slice {
+			return "", InvalidCompare(1)
+		}
+		entries := make([]string, 0)
+		for i := 0; i < val.Len(); i++ {
+			entries = append(entries, "?")
+			*p.params = append(*p.params, sqlValue(val.Index(i).Interface()))
+		}
+		return output + " (" + strings.Join(entries, ", ") + ")", nil
+	}
+	*p.params = append(*p.params, sqlValue(v))
+	return output + " ?", nil
+}
+
+/*
+This prints the string ops.  The patterns print as LIKE, with a prefix or a substring escaped into a pattern, and case insensitive equality compares both sides in lower case.  Neither needs a regular expression extension
+*/
+func (p sqlitePrinter) printString(column string, r fieldMatcher) (string, error) {
+	equals := "="
+	if r.Op == NOT_IEQ {
+		equals = "!="
+	}
+	if y, ok := r.Value.(fieldYielder); ok {
+		switch r.Op {
+		case LIKE, NOT_LIKE:
+			return column + " " + p.operator(r.Op) + " " + p.column(y.Name), nil
+		case IEQ, NOT_IEQ:
+			return "LOWER(" + column + ") " + equals + " LOWER(" + p.column(y.Name) + ")", nil
+		}
+		//A prefix held in another column would need concatenation, which is spelled differently by each database
+		return "", InvalidCompare(1)
+	}
+	v := r.Value
+	if y, ok := v.(Yielder); ok {
+		result, err := y.Yield()
+		if err != nil {
+			return "", err
+		}
+		v = result
+	}
+	value, ok := v.(string)
+	if !ok {
+		return "", InvalidCompare(1)
+	}
+	escape := ""
+	if r.Op != LIKE && r.Op != NOT_LIKE && r.Op != IEQ && r.Op != NOT_IEQ {
+		value = likeLiteral(r.Op, value)
+		escape = " ESCAPE '" + likeEscape + "'"
+	}
+	if p.params != nil {
+		*p.params = append(*p.params, value)
+		value = "?"
+	} else {
+		value = sqlLiteral(value)
+	}
+	if r.Op == IEQ || r.Op == NOT_IEQ {
+		return "LOWER(" + column + ") " + equals + " LOWER(" + value + ")", nil
+	}
+	return column + " " + p.operator(r.Op) + " " + value + escape, nil
+}
+
+/*
+This prints a BETWEEN, binding or writing both of its bounds
+*/
+func (p sqlitePrinter) printBetween(column string, r fieldMatcher) (string, error) {
+	val := reflect.ValueOf(r.Value)
+	if val.Kind() != reflect.Slice || val.Len() != 2 {
+		return "", InvalidCompare(1)
+	}
+	bounds := make([]string, 2)
+	for i := range bounds {
+		bound := val.Index(i).Interface()
+		if p.params != nil {
+			*p.params = append(*p.params, sqlValue(bound))
+			bounds[i] = "?"
+		} else {
+			bounds[i] = sqlLiteral(bound)
+		}
+	}
+	return column + " " + r.Op.String() + " " + bounds[0] + " AND " + bounds[1], nil
+}
+
+/*
+This writes a value into the statement, for the printer without placeholders.  Times are quoted text in SqlTimeLayout
+*/
+func sqlLiteral(value interface{}) string {
+	switch v := sqlValue(value).(type) {
+	case string:
+		return "'" + v + "'"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -2,6 +2,7 @@ package matcher
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -180,3 +181,118 @@ func ExampleNewSqlitePrinter_printHello() {
 	//Output:
 	//A = 1 AND B = 0
 }
+
+func TestSqliteParamPrinterFields(t *testing.T) {
+	assertMatch := func(expected string, expectedParams []interface{}, matcher Matcher) {
+		printer := NewSqliteParamPrinter()
+		result, params, err := printer.PrintParams(matcher)
+		if err != nil {
+			t.Errorf("Unexpected error printing %v: %v", expected, err)
+		}
+		if result != expected {
+			t.Errorf("got:%v, want:%v", result, expected)
+		}
+		if !reflect.DeepEqual(params, expectedParams) {
+			t.Errorf("got:%v, want:%v", params, expectedParams)
+		}
+	}
+	assertMatch("1", nil, Any())
+	assertMatch("0", nil, None())
+	assertMatch("_ = ?", []interface{}{1}, Eq(1))
+	assertMatch("_ = ?", []interface{}{"1"}, Eq("1"))
+	assertMatch("_ = ?", []interface{}{"it's \"quoted\""}, Eq("it's \"quoted\""))
+	assertMatch("_ != ?", []interface{}{true}, Neq(true))
+	assertMatch("_ IN (?, ?, ?)", []interface{}{1, 2, 3}, In([]int{1, 2, 3}))
+	assertMatch("_ NOT IN (?, ?)", []interface{}{"a", "b"}, NotIn([]string{"a", "b"}))
+	assertMatch("_ MATCH ?", []interface{}{"1"}, Match("1"))
+	assertMatch("_ = ?", []interface{}{5}, Eq(NewLambdaYield(func() (interface{}, error) { return 5, nil })))
+
+	m := NewStructMatcher()
+	m.AddField("A", Eq(1))
+	m.AddField("B", In([]string{"x", "y"}))
+	m.AddField("C", Or(Lt(0.5), Gt(10.5)))
+	assertMatch("A = ? AND B IN (?, ?) AND (C < ? OR C > ?)", []interface{}{1, "x", "y", 0.5, 10.5}, m)
+
+	m = NewStructMatcher()
+	m.AddField("A", Eq(m.Field("B")))
+	assertMatch("A = B", nil, m)
+}
+
+/*
+The param printer keeps values out of the statement, so that they can be bound by the driver
+*/
+func ExampleNewSqliteParamPrinter() {
+	m := NewStructMatcher()
+	m.AddField("A", Eq("Robert'); DROP TABLE Students;--"))
+	m.AddField("B", In([]int{1, 2}))
+
+	printer := NewSqliteParamPrinter()
+	result, params, _ := printer.PrintParams(m)
+	fmt.Println(result)
+	fmt.Println(params)
+	//Output:
+	//A = ? AND B IN (?, ?)
+	//[Robert'); DROP TABLE Students;-- 1 2]
+}
+
+/*
+The column printer renames fields to the columns they are stored in, including fields compared against other fields
+*/
+func ExampleNewSqliteColumnPrinter() {
+	m := NewStructMatcher()
+	m.AddField("A", Eq("bacon"))
+	m.AddField("B", Lt(m.Field("A")))
+	m.AddField("C", Gt(1))
+
+	printer := NewSqliteColumnPrinter(map[string]string{"A": "`a_column`", "B": "Foo.`B`"})
+	result, params, _ := printer.PrintParams(m)
+	fmt.Println(result)
+	fmt.Println(params)
+	//Output:
+	//`a_column` = ? AND Foo.`B` < `a_column` AND C > ?
+	//[bacon 1]
+}
+
+type doubleQuoteDialect struct{}
+
+func (d doubleQuoteDialect) QuoteIdentifier(name string) string { return "\"" + name + "\"" }
+func (d doubleQuoteDialect) RegexOperator(negate bool) string {
+	if negate {
+		return "!~"
+	}
+	return "~"
+}
+func (d doubleQuoteDialect) LikeOperator(negate bool) string {
+	if negate {
+		return "NOT ILIKE"
+	}
+	return "ILIKE"
+}
+
+func ExampleNewDialectPrinter() {
+	m := NewStructMatcher()
+	m.AddField("A", Match("^ba"))
+	m.AddField("B", NotMatch("con$"))
+	m.AddField("C", In([]int{1, 2}))
+
+	printer := NewDialectPrinter(doubleQuoteDialect{}, map[string]string{"A": "\"a_column\""})
+	result, params, _ := printer.PrintParams(m)
+	fmt.Println(result)
+	fmt.Println(params)
+	for _, m := range []Matcher{Any(), None()} {
+		result, _, _ = printer.PrintParams(m)
+		fmt.Println(result)
+	}
+
+	//Each part of a path is quoted
+	m = NewStructMatcher()
+	m.AddField("Device.Name", Eq("bacon"))
+	result, _, _ = printer.PrintParams(m)
+	fmt.Println(result)
+	//Output:
+	//"a_column" ~ ? AND "B" !~ ? AND "C" IN (?, ?)
+	//[^ba con$ 1 2]
+	//1 = 1
+	//1 = 0
+	//"Device"."Name" = ?
+}
@@ -2,6 +2,7 @@ package matcher
 
 import (
 	"reflect"
+	"strings"
 )
 
 type fieldYielder struct {
@@ -9,7 +10,34 @@ type fieldYielder struct {
 	Name    string
 }
 
+/*
+This finds the value of a field in the record.  The name may be a dotted path, such as Device.Name, which is followed through nested structs, pointers and maps.  A path through a nil pointer or an invalid sql.Null type reaches NULL.  A map key that contains the whole path is used before the path is followed
+*/
 func lookup(record interface{}, name string) (interface{}, error) {
+	output, err := lookupOne(record, name)
+	if err == nil || !strings.Contains(name, ".") {
+		return output, err
+	}
+	output = record
+	for i, part := range strings.Split(name, ".") {
+		if i > 0 {
+			null := false
+			if output, null = unwrapNull(output); null {
+				return nil, errNullPath
+			}
+		}
+		output, err = lookupOne(output, part)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+//This is returned by lookup when a path passes through a nil pointer, so the value at the end of it is NULL
+var errNullPath = InvalidCompare(3)
+
+func lookupOne(record interface{}, name string) (interface{}, error) {
 	switch r := record.(type) {
 	case map[string]interface{}:
 		output, ok := r[name]
@@ -25,7 +53,13 @@ func lookup(record interface{}, name string) (interface{}, error) {
 		if !val.IsValid() {
 			return nil, InvalidCompare(2)
 		}
-		rVal := val.FieldByName(name)
+		var rVal reflect.Value
+		switch {
+		case val.Kind() == reflect.Struct:
+			rVal = val.FieldByName(name)
+		case val.Kind() == reflect.Map && val.Type().Key().Kind() == reflect.String:
+			rVal = val.MapIndex(reflect.ValueOf(name).Convert(val.Type().Key()))
+		}
 		if !rVal.IsValid() {
 			return nil, InvalidCompare(2)
 		}
@@ -46,6 +80,9 @@ type structMatcher struct {
 	Fields map[string]Matcher
 }
 
+/*
+This adds a matcher for the named field.  The name may be a dotted path into nested structs or maps, such as Device.Name
+*/
 func (field *structMatcher) AddField(name string, matcher Matcher) {
 	if field.Fields == nil {
 		field.Fields = make(map[string]Matcher)
@@ -58,24 +95,35 @@ func (field *structMatcher) Field(Name string) Yielder {
 }
 
 func (field *structMatcher) Match(record interface{}) (bool, error) {
+	result, err := field.matchTernary(record)
+	return result == isTrue, err
+}
+
+func (field *structMatcher) matchTernary(record interface{}) (truth, error) {
 	if field.Fields == nil {
 		field.Fields = make(map[string]Matcher)
 	}
 	field.record = record
-	valid := true
+	accum := isTrue
 	for name, matcher := range field.Fields {
 		attr, err := lookup(record, name)
+		if err == errNullPath {
+			//A path through a nil pointer reaches NULL
+			attr, err = nil, nil
+		}
 		if err != nil {
-			return false, InvalidCompare(1)
+			return isFalse, InvalidCompare(1)
 		}
-		localMatch, err := matcher.Match(attr)
+		localMatch, err := matchTernary(matcher, attr)
 		if err != nil {
-			return localMatch, err
+			return isFalse, err
+		}
+		if localMatch == isFalse {
+			return isFalse, nil
 		}
-		valid = valid && localMatch
-		if !valid {
-			return false, nil
+		if localMatch == isUnknown {
+			accum = isUnknown
 		}
 	}
-	return true, nil
+	return accum, nil
 }
@@ -103,3 +103,46 @@ func TestStructMatchWithYield(t *testing.T) {
 
 	matchError("A mismatched type", MissingTargetField{B: 1})
 }
+
+func TestStructMatchPath(t *testing.T) {
+	type Location struct {
+		Name string
+	}
+	type Device struct {
+		Name     string
+		Location Location
+		Backup   *Location
+		Tags     map[string]string
+	}
+	matcher := NewStructMatcher()
+	matcher.AddField("Backup.Name", Eq(matcher.Field("Location.Name")))
+	matcher.AddField("Tags.Site", Eq("east"))
+
+	assert := func(message string, record interface{}, expected, hasError bool) {
+		match, err := matcher.Match(record)
+		if match != expected || (err != nil) != hasError {
+			t.Errorf("%v: expected %v and error %v, got %v and %v", message, expected, hasError, match, err)
+		}
+	}
+	device := Device{Location: Location{Name: "A"}, Backup: &Location{Name: "A"}, Tags: map[string]string{"Site": "east"}}
+	assert("A nested struct", device, true, false)
+	assert("A pointer to a nested struct", &device, true, false)
+	device.Backup = &Location{Name: "B"}
+	assert("A different nested value", device, false, false)
+	device.Backup = nil
+	assert("A nil pointer on the path is NULL", device, false, false)
+	device.Backup = &Location{Name: "A"}
+	device.Tags = map[string]string{}
+	assert("A missing map key", device, false, true)
+
+	assert("Nested maps", map[string]interface{}{
+		"Location": map[string]interface{}{"Name": "A"},
+		"Backup":   Location{Name: "A"},
+		"Tags":     map[string]interface{}{"Site": "east"},
+	}, true, false)
+	assert("A key with the whole path", map[string]interface{}{
+		"Location.Name": "A",
+		"Backup.Name":   "A",
+		"Tags.Site":     "east",
+	}, true, false)
+}
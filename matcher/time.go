@@ -0,0 +1,99 @@
+package matcher
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+This is the layout times are written in for sql.  Times are converted to UTC, and every digit of the nanoseconds is kept, so that the text sorts in time order
+*/
+const SqlTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+//This matches a duration as time.ParseDuration reads it, and as Duration.String writes it
+const durationPattern = "(\\d+(\\.\\d+)?(ns|us|µs|ms|s|m|h))+"
+
+var relativePattern = regexp.MustCompile("^now(\\s*[+-]\\s*" + durationPattern + ")?")
+
+type relativeTime time.Duration
+
+/*
+This returns a yielder for the time when the matcher is run, moved by the offset.  Compare against it to match a window that moves with the clock, such as the last 5 minutes
+
+    match.AddField("Created", Gte(Now(-5 * time.Minute)))
+
+The parser reads the same thing as Created >= now - 5m
+*/
+func Now(offset time.Duration) Yielder {
+	return relativeTime(offset)
+}
+
+func (r relativeTime) Yield() (interface{}, error) {
+	return time.Now().Add(time.Duration(r)), nil
+}
+
+func (r relativeTime) String() string {
+	switch {
+	case r < 0:
+		return "now - " + (-time.Duration(r)).String()
+	case r > 0:
+		return "now + " + time.Duration(r).String()
+	}
+	return "now"
+}
+
+/*
+This reads a relative time, such as now - 5m, from the token the tokenizer made of it
+*/
+func parseRelative(value string) (relativeTime, bool) {
+	if !strings.HasPrefix(value, "now") {
+		return 0, false
+	}
+	offset := strings.TrimPrefix(value, "now")
+	if offset == "" {
+		return 0, true
+	}
+	duration, err := time.ParseDuration(offset)
+	if err != nil {
+		return 0, false
+	}
+	return relativeTime(duration), true
+}
+
+/*
+This promotes a literal to a time or a duration.  Times are RFC3339, and either may be quoted
+*/
+func promoteToTime(typ reflect.Type, value string) (interface{}, error) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "\""), "\"")
+	if typ == durationType {
+		return time.ParseDuration(value)
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+/*
+This is true for the types that the parser treats as times, rather than by their kind
+*/
+func isTime(typ reflect.Type) bool {
+	return typ == timeType || typ == durationType
+}
+
+/*
+This converts a time to the value bound to a sql placeholder, which is SqlTimeLayout text for a time, and nanoseconds for a duration.  Other values are returned as they are
+*/
+func sqlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(SqlTimeLayout)
+	case time.Duration:
+		return int64(v)
+	}
+	return value
+}
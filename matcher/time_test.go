@@ -0,0 +1,117 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Times need their type in the parser context, since their kind is only struct.  They are written in RFC3339, or relative to when the matcher runs
+*/
+func ExampleNow() {
+	p, _ := NewParser(map[string]reflect.Type{
+		"Created": reflect.TypeOf(time.Time{}),
+		"Timeout": reflect.TypeOf(time.Duration(0)),
+	})
+	recent, _ := p.Parse(`Created >= now - 5m AND Timeout < 1m30s`)
+	printed, _ := NewDefaultPrinter().Print(recent)
+	fmt.Println(printed)
+
+	type Job struct {
+		Created time.Time
+		Timeout time.Duration
+	}
+	fmt.Println(recent.Match(Job{Created: time.Now().Add(-time.Minute), Timeout: time.Minute}))
+	fmt.Println(recent.Match(Job{Created: time.Now().Add(-time.Hour), Timeout: time.Minute}))
+
+	since, _ := p.Parse(`Created > 2020-01-02T03:04:05Z`)
+	printed, params, _ := NewSqliteParamPrinter().PrintParams(since)
+	fmt.Println(printed, params)
+
+	//Output:
+	//Created >= now - 5m0s AND Timeout < 1m30s
+	//true <nil>
+	//false <nil>
+	//Created > ? [2020-01-02T03:04:05.000000000Z]
+}
+
+func TestTimeMatch(t *testing.T) {
+	base := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	elsewhere := base.In(time.FixedZone("Elsewhere", 3600))
+	cases := []struct {
+		m        Matcher
+		record   interface{}
+		expected bool
+	}{
+		{Eq(base), elsewhere, true},
+		{Neq(base), elsewhere, false},
+		{Lt(base), base.Add(-1), true},
+		{Lt(base), elsewhere, false},
+		{Lte(base), elsewhere, true},
+		{Gt(base), base.Add(1), true},
+		{Gte(base), base.Add(-1), false},
+		{In([]time.Time{elsewhere}), base, true},
+		{NotIn([]time.Time{elsewhere}), base, false},
+		{Gte(Now(-time.Minute)), time.Now(), true},
+		{Lt(Now(-time.Minute)), time.Now(), false},
+		{Eq(time.Second), time.Second, true},
+		{Lt(time.Second), time.Millisecond, true},
+		{In([]time.Duration{time.Second, time.Minute}), time.Minute, true},
+	}
+	for _, c := range cases {
+		printed, _ := NewDefaultPrinter().Print(c.m)
+		result, err := c.m.Match(c.record)
+		if err != nil || result != c.expected {
+			t.Errorf("Expected %v to be %v for %v, got %v %v", printed, c.expected, c.record, result, err)
+		}
+	}
+}
+
+func TestTimeParse(t *testing.T) {
+	p, _ := NewParser(map[string]interface{}{"T": time.Time{}, "D": time.Duration(0)})
+	base := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	valid := map[string]bool{
+		`T = 2020-01-02T03:04:05.6Z`:                            true,
+		`T = "2020-01-02T04:04:05.6+01:00"`:                     true,
+		`T IN (2019-01-01T00:00:00Z, "2020-01-02T03:04:05.6Z")`: true,
+		`T < now`:      true,
+		`T > now + 1h`: false,
+		`D = 1.5s`:     false,
+		`D >= -1h`:     true,
+	}
+	record := map[string]interface{}{"T": base, "D": time.Second}
+	for input, expected := range valid {
+		m, err := p.Parse(input)
+		if err != nil {
+			t.Errorf("Could not parse %v: %v", input, err)
+			continue
+		}
+		result, err := m.Match(record)
+		if err != nil || result != expected {
+			t.Errorf("Expected %v to be %v, got %v %v", input, expected, result, err)
+		}
+		printed, _ := NewDefaultPrinter().Print(m)
+		if strings.Contains(printed, "[") {
+			//The default printer writes lists in brackets, which the parser does not read
+			continue
+		}
+		if _, err := p.Parse(printed); err != nil {
+			t.Errorf("Could not parse %v back, as printed from %v: %v", printed, input, err)
+		}
+	}
+
+	//A field called now is still a field
+	named, _ := NewParser(map[string]interface{}{"now": 0, "nowhere": 0})
+	if _, err := named.Parse(`now = 0 AND nowhere = 1`); err != nil {
+		t.Errorf("Fields called now should parse, got %v", err)
+	}
+
+	for _, input := range []string{`T = 2020-13-01T00:00:00Z`, `T = 5`, `D = 5`, `D = now`} {
+		if _, err := p.Parse(input); err == nil {
+			t.Errorf("Expected %v not to parse", input)
+		}
+	}
+}
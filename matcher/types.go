@@ -30,6 +30,18 @@ const (
 	NOT_IN
 	MATCH
 	NOT_MATCH
+	IS_NULL
+	IS_NOT_NULL
+	LIKE
+	NOT_LIKE
+	BETWEEN
+	NOT_BETWEEN
+	STARTS_WITH
+	NOT_STARTS_WITH
+	CONTAINS
+	NOT_CONTAINS
+	IEQ
+	NOT_IEQ
 )
 
 /*
@@ -61,7 +73,7 @@ type Parser interface {
 }
 
 /*
-The yielder is used to provide dynmaic values in a matcher.  It is needs let expressions such as 'A = B' work properly.  It can also be used to determine how an item compares to a dynamic value, such as a time window (e.g. "Last 5 minutes")
+The yielder is used to provide dynmaic values in a matcher.  It is needs let expressions such as 'A = B' work properly.  It can also be used to determine how an item compares to a dynamic value, such as a time window (e.g. "Last 5 minutes").  Now returns the yielder for that case
 */
 type Yielder interface {
 	Yield() (interface{}, error)
@@ -70,3 +82,11 @@ type Yielder interface {
 type Printer interface {
 	Print(m Matcher) (string, error)
 }
+
+/*
+This is a printer that renders values as placeholders instead of literals.  The values are returned separately, in the order the placeholders appear, so that they can be bound by the database driver
+*/
+type ParamPrinter interface {
+	Printer
+	PrintParams(m Matcher) (string, []interface{}, error)
+}
@@ -42,38 +42,57 @@ func (a errorMatch) Match(record interface{}) (bool, error) {
 }
 
 func (a invertMatch) Match(record interface{}) (bool, error) {
-	result, err := a.M.Match(record)
+	result, err := a.matchTernary(record)
+	return result == isTrue, err
+}
+
+func (a invertMatch) matchTernary(record interface{}) (truth, error) {
+	result, err := matchTernary(a.M, record)
 	if err != nil {
-		return false, err
+		return isFalse, err
 	}
-	return !result, nil
+	return result.not(), nil
 }
 
 func (a andMatch) Match(record interface{}) (bool, error) {
-	accum := true
+	result, err := a.matchTernary(record)
+	return result == isTrue, err
+}
+
+func (a andMatch) matchTernary(record interface{}) (truth, error) {
+	accum := isTrue
 	for _, m := range a.Matchers {
-		result, err := m.Match(record)
+		result, err := matchTernary(m, record)
 		if err != nil {
-			return false, err
+			return isFalse, err
+		}
+		if result == isFalse {
+			return isFalse, nil
 		}
-		accum = accum && result
-		if !accum {
-			break
+		if result == isUnknown {
+			accum = isUnknown
 		}
 	}
 	return accum, nil
 }
 
 func (a orMatch) Match(record interface{}) (bool, error) {
-	accum := false
+	result, err := a.matchTernary(record)
+	return result == isTrue, err
+}
+
+func (a orMatch) matchTernary(record interface{}) (truth, error) {
+	accum := isFalse
 	for _, m := range a.Matchers {
-		result, err := m.Match(record)
+		result, err := matchTernary(m, record)
 		if err != nil {
-			return false, err
+			return isFalse, err
+		}
+		if result == isTrue {
+			return isTrue, nil
 		}
-		accum = accum || result
-		if accum {
-			break
+		if result == isUnknown {
+			accum = isUnknown
 		}
 	}
 	return accum, nil
@@ -158,6 +177,61 @@ func NotMatch(record string) Matcher {
 	return fieldMatcher{Op: NOT_MATCH, Value: record}
 }
 
+/*
+This returns a matcher that will test a string against a sql LIKE pattern, where % matches any run of characters and _ matches any one.  Like sqlite, the match ignores the case of letters
+*/
+func Like(pattern string) Matcher {
+	return fieldMatcher{Op: LIKE, Value: pattern}
+}
+
+func NotLike(pattern string) Matcher {
+	return fieldMatcher{Op: NOT_LIKE, Value: pattern}
+}
+
+/*
+This returns a matcher that will test that a tested value is between two others, including both of them.  The bounds must be the same type
+*/
+func Between(low, high interface{}) Matcher {
+	return fieldMatcher{Op: BETWEEN, Value: bounds(low, high)}
+}
+
+func NotBetween(low, high interface{}) Matcher {
+	return fieldMatcher{Op: NOT_BETWEEN, Value: bounds(low, high)}
+}
+
+/*
+This returns a matcher that will test that a string starts with a prefix, ignoring the case of letters as LIKE does
+*/
+func StartsWith(prefix string) Matcher {
+	return fieldMatcher{Op: STARTS_WITH, Value: prefix}
+}
+
+func NotStartsWith(prefix string) Matcher {
+	return fieldMatcher{Op: NOT_STARTS_WITH, Value: prefix}
+}
+
+/*
+This returns a matcher that will test that a string contains another, ignoring the case of letters as LIKE does
+*/
+func Contains(substring string) Matcher {
+	return fieldMatcher{Op: CONTAINS, Value: substring}
+}
+
+func NotContains(substring string) Matcher {
+	return fieldMatcher{Op: NOT_CONTAINS, Value: substring}
+}
+
+/*
+This returns a matcher that will test that a string is equal to another, ignoring the case of letters
+*/
+func Ieq(record string) Matcher {
+	return fieldMatcher{Op: IEQ, Value: record}
+}
+
+func NotIeq(record string) Matcher {
+	return fieldMatcher{Op: NOT_IEQ, Value: record}
+}
+
 /*
 This function will return a matcher that is the logical inverse of the provided matcher.  Sometimes it will wrap the provided matcher with an Inverter, other times it will perform an optimization in order to keep the call tree as small as possible
 */
@@ -191,6 +265,30 @@ func Not(matcher Matcher) Matcher {
 			return NotMatch(r.Value.(string))
 		case NOT_MATCH:
 			return Match(r.Value.(string))
+		case IS_NULL:
+			return IsNotNull()
+		case IS_NOT_NULL:
+			return IsNull()
+		case LIKE:
+			return fieldMatcher{Op: NOT_LIKE, Value: r.Value}
+		case NOT_LIKE:
+			return fieldMatcher{Op: LIKE, Value: r.Value}
+		case BETWEEN:
+			return fieldMatcher{Op: NOT_BETWEEN, Value: r.Value}
+		case NOT_BETWEEN:
+			return fieldMatcher{Op: BETWEEN, Value: r.Value}
+		case STARTS_WITH:
+			return fieldMatcher{Op: NOT_STARTS_WITH, Value: r.Value}
+		case NOT_STARTS_WITH:
+			return fieldMatcher{Op: STARTS_WITH, Value: r.Value}
+		case CONTAINS:
+			return fieldMatcher{Op: NOT_CONTAINS, Value: r.Value}
+		case NOT_CONTAINS:
+			return fieldMatcher{Op: CONTAINS, Value: r.Value}
+		case IEQ:
+			return fieldMatcher{Op: NOT_IEQ, Value: r.Value}
+		case NOT_IEQ:
+			return fieldMatcher{Op: IEQ, Value: r.Value}
 		default:
 			return invertMatch{M: matcher}
 		}
@@ -0,0 +1,297 @@
+/*
+This is a package for exposing a record service over http.  It generates the standard REST endpoints for a record type, using goflect.GetInfo to find the primary key and goflect.Parse to turn query strings into matchers
+
+The handler serves the following routes, relative to where it is mounted
+
+    GET    /           List the records, restricted by the optional ?where= expression
+    GET    /?nominal=  Read the record whose nominal field is the name
+    POST   /           Create a record from the JSON body
+    GET    /docs       Describe the fields of the record type
+    GET    /{id}       Read a record by its primary key
+    PUT    /{id}       Update a record by its primary key, from the JSON body
+    DELETE /{id}       Delete a record by its primary key
+
+Errors from the service are returned as 409 when they break a UNIQUE or FOREIGN KEY constraint, 400 when they try to change an immutable field, and 500 otherwise.  Use http.StripPrefix to mount the handler below a path
+*/
+package rest
+
+import (
+	"encoding/json"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	records "git.sevone.com/sdevlin/goflect.git/sql"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type restHandler struct {
+	service records.RecordService
+	typ     reflect.Type
+	fields  []goflect.Info
+	primary *goflect.Info
+}
+
+/*
+This is the body returned with any non 2xx response
+*/
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+/*
+This creates a handler that exposes CRUD operations for the type of record, backed by the service
+*/
+func NewHandler(service records.RecordService, record interface{}) http.Handler {
+	typ := reflect.TypeOf(record)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	handler := &restHandler{service: service, typ: typ}
+	handler.fields = goflect.GetInfo(reflect.New(typ).Interface())
+	for i, field := range handler.fields {
+		if field.IsPrimary {
+			handler.primary = &handler.fields[i]
+		}
+	}
+	return handler
+}
+
+func (h *restHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "" && r.Method == "GET" && r.URL.Query()["nominal"] != nil:
+		h.nominal(w, r, r.URL.Query().Get("nominal"))
+	case path == "" && r.Method == "GET":
+		h.list(w, r)
+	case path == "" && r.Method == "POST":
+		h.create(w, r)
+	case path == "docs" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, h.fields)
+	case strings.Contains(path, "/"):
+		writeError(w, http.StatusNotFound, "Not found")
+	case path == "" || path == "docs":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	case r.Method == "GET":
+		h.read(w, r, path)
+	case r.Method == "PUT":
+		h.update(w, r, path)
+	case r.Method == "DELETE":
+		h.remove(w, r, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
+
+/*
+This picks the status for an error from the service.  The drivers and the memory service all report constraints as "... constraint failed", so the message is the only thing they share
+*/
+func errorStatus(err error) int {
+	if _, ok := err.(records.ImmutableFieldError); ok {
+		return http.StatusBadRequest
+	}
+	message := err.Error()
+	if strings.Contains(message, "UNIQUE constraint failed") || strings.Contains(message, "FOREIGN KEY constraint failed") {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *restHandler) newRecord() reflect.Value {
+	return reflect.New(h.typ)
+}
+
+/*
+This converts the id in the url to a matcher on the primary key, using the kind of the primary key
+*/
+func (h *restHandler) idMatcher(id string) (matcher.Matcher, reflect.Value, error) {
+	if h.primary == nil {
+		return nil, reflect.Value{}, records.RecordError("No primary key found")
+	}
+	value := reflect.New(h.typ).Elem().FieldByName(h.primary.Name)
+	switch h.primary.Kind {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		i, err := strconv.ParseInt(id, 10, value.Type().Bits())
+		if err != nil {
+			return nil, value, err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		u, err := strconv.ParseUint(id, 10, value.Type().Bits())
+		if err != nil {
+			return nil, value, err
+		}
+		value.SetUint(u)
+	case reflect.String:
+		value.SetString(id)
+	default:
+		return nil, value, records.RecordError("Unsupported primary key kind " + h.primary.Kind.String())
+	}
+	match := matcher.NewStructMatcher()
+	match.AddField(h.primary.Name, matcher.Eq(value.Interface()))
+	return match, value, nil
+}
+
+/*
+This reads every record that matches into a slice of the record type.  The request's context is passed on, so the read stops if the client goes away
+*/
+func (h *restHandler) readWhere(r *http.Request, match matcher.Matcher) (interface{}, int, error) {
+	record := h.newRecord()
+	cursor, err := h.service.ReadAllWhereContext(r.Context(), record.Interface(), match)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close()
+	output := reflect.MakeSlice(reflect.SliceOf(h.typ), 0, 0)
+	for cursor.Next() {
+		err = cursor.Scan(record.Interface())
+		if err != nil {
+			return nil, 0, err
+		}
+		output = reflect.Append(output, record.Elem())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+	return output.Interface(), output.Len(), nil
+}
+
+func (h *restHandler) list(w http.ResponseWriter, r *http.Request) {
+	match := matcher.Any()
+	if where := r.URL.Query().Get("where"); where != "" {
+		parsed, err := goflect.Parse(h.newRecord().Interface(), where)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		match = parsed
+	}
+	output, _, err := h.readWhere(r, match)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, output)
+}
+
+func (h *restHandler) nominal(w http.ResponseWriter, r *http.Request, name string) {
+	found := false
+	for _, field := range h.fields {
+		found = found || field.IsNominal
+	}
+	if !found {
+		writeError(w, http.StatusBadRequest, "No nominal field found on "+h.typ.Name())
+		return
+	}
+	record := h.newRecord()
+	err := h.service.GetByNominal(name, record.Interface())
+	if _, ok := err.(records.RecordError); ok {
+		//The nominal field is known, so this is a missing or shared name
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, record.Interface())
+}
+
+func (h *restHandler) create(w http.ResponseWriter, r *http.Request) {
+	record := h.newRecord()
+	err := json.NewDecoder(r.Body).Decode(record.Interface())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.service.CreateContext(r.Context(), record.Interface())
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, record.Interface())
+}
+
+func (h *restHandler) read(w http.ResponseWriter, r *http.Request, id string) {
+	match, _, err := h.idMatcher(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	output, count, err := h.readWhere(r, match)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	if count == 0 {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, reflect.ValueOf(output).Index(0).Interface())
+}
+
+func (h *restHandler) update(w http.ResponseWriter, r *http.Request, id string) {
+	match, value, err := h.idMatcher(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	_, count, err := h.readWhere(r, match)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	if count == 0 {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+
+	record := h.newRecord()
+	err = json.NewDecoder(r.Body).Decode(record.Interface())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	//The url is authoritative for the primary key
+	record.Elem().FieldByName(h.primary.Name).Set(value)
+	err = h.service.UpdateContext(r.Context(), record.Interface())
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, record.Interface())
+}
+
+func (h *restHandler) remove(w http.ResponseWriter, r *http.Request, id string) {
+	match, _, err := h.idMatcher(id)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	_, count, err := h.readWhere(r, match)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	if count == 0 {
+		writeError(w, http.StatusNotFound, "Record not found")
+		return
+	}
+	err = h.service.DeleteAllWhereContext(r.Context(), h.newRecord().Interface(), match)
+	if err != nil {
+		writeError(w, errorStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"database/sql"
+	"fmt"
+	records "git.sevone.com/sdevlin/goflect.git/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type Device struct {
+	Id   int64  `sql:"primary,autoincrement"`
+	Name string `sql:"nominal,unique"`
+	Port int64
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	c, _ := sql.Open("sqlite3", ":memory:")
+	c.SetMaxOpenConns(1)
+	service := records.NewSqliteService(c)
+	err := service.Define(&Device{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.StripPrefix("/devices", NewHandler(service, Device{})))
+}
+
+func call(t *testing.T, method, target, body string) (int, string) {
+	request, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	contents, _ := ioutil.ReadAll(response.Body)
+	return response.StatusCode, strings.TrimSpace(string(contents))
+}
+
+/*
+This shows the full lifecycle of a record through the generated endpoints
+*/
+func ExampleNewHandler() {
+	c, _ := sql.Open("sqlite3", ":memory:")
+	c.SetMaxOpenConns(1)
+	service := records.NewSqliteService(c)
+	service.Define(&Device{})
+
+	server := httptest.NewServer(http.StripPrefix("/devices", NewHandler(service, Device{})))
+	defer server.Close()
+
+	show := func(method, path, body string) {
+		request, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer response.Body.Close()
+		contents, _ := ioutil.ReadAll(response.Body)
+		fmt.Println(strings.TrimSpace(fmt.Sprint(response.StatusCode, " ", string(contents))))
+	}
+
+	show("POST", "/devices/", `{"Name": "Router", "Port": 22}`)
+	show("POST", "/devices/", `{"Name": "Switch", "Port": 23}`)
+	show("GET", "/devices/?where="+url.QueryEscape(`Port > 22`), "")
+	show("PUT", "/devices/1", `{"Name": "Core Router", "Port": 2222}`)
+	show("GET", "/devices/1", "")
+	show("DELETE", "/devices/2", "")
+	show("GET", "/devices/", "")
+
+	//Output:
+	//201 {"Id":1,"Name":"Router","Port":22}
+	//201 {"Id":2,"Name":"Switch","Port":23}
+	//200 [{"Id":2,"Name":"Switch","Port":23}]
+	//200 {"Id":1,"Name":"Core Router","Port":2222}
+	//200 {"Id":1,"Name":"Core Router","Port":2222}
+	//204
+	//200 [{"Id":1,"Name":"Core Router","Port":2222}]
+}
+
+func TestHandlerErrors(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	assertStatus := func(expected int, method, path, body string) {
+		status, contents := call(t, method, server.URL+path, body)
+		if status != expected {
+			t.Errorf("%v %v: got:%v, want:%v (%v)", method, path, status, expected, contents)
+		}
+	}
+
+	assertStatus(http.StatusNotFound, "GET", "/devices/1", "")
+	assertStatus(http.StatusNotFound, "PUT", "/devices/1", `{"Name": "Router"}`)
+	assertStatus(http.StatusNotFound, "DELETE", "/devices/1", "")
+	assertStatus(http.StatusBadRequest, "GET", "/devices/bacon", "")
+	assertStatus(http.StatusBadRequest, "POST", "/devices/", `{"Name": `)
+	assertStatus(http.StatusBadRequest, "GET", "/devices/?where="+url.QueryEscape("Bacon = 1"), "")
+	assertStatus(http.StatusBadRequest, "GET", "/devices/?where="+url.QueryEscape("Port = \"x\""), "")
+	assertStatus(http.StatusMethodNotAllowed, "DELETE", "/devices/", "")
+	assertStatus(http.StatusMethodNotAllowed, "PATCH", "/devices/1", "")
+	assertStatus(http.StatusNotFound, "GET", "/devices/1/2", "")
+	assertStatus(http.StatusNotFound, "GET", "/devices/?nominal=Router", "")
+
+	assertStatus(http.StatusCreated, "POST", "/devices/", `{"Name": "Router"}`)
+	assertStatus(http.StatusCreated, "POST", "/devices/", `{"Name": "Switch"}`)
+	assertStatus(http.StatusConflict, "POST", "/devices/", `{"Name": "Router"}`)
+	assertStatus(http.StatusConflict, "PUT", "/devices/2", `{"Name": "Router"}`)
+
+	if status := errorStatus(records.ImmutableFieldError{Type: "Device", Field: "Id"}); status != http.StatusBadRequest {
+		t.Errorf("ImmutableFieldError: got:%v, want:%v", status, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerNominal(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	call(t, "POST", server.URL+"/devices/", `{"Name": "Router", "Port": 22}`)
+	call(t, "POST", server.URL+"/devices/", `{"Name": "Switch", "Port": 23}`)
+
+	status, contents := call(t, "GET", server.URL+"/devices/?nominal="+url.QueryEscape("Switch"), "")
+	if status != http.StatusOK || contents != `{"Id":2,"Name":"Switch","Port":23}` {
+		t.Errorf("Unexpected nominal read: %v %v", status, contents)
+	}
+	status, _ = call(t, "GET", server.URL+"/devices/?nominal=", "")
+	if status != http.StatusNotFound {
+		t.Errorf("Unexpected status for an empty name: %v", status)
+	}
+
+	type Unnamed struct {
+		Id int64 `sql:"primary"`
+	}
+	unnamed := httptest.NewServer(NewHandler(records.NewDummyService(), Unnamed{}))
+	defer unnamed.Close()
+	status, _ = call(t, "GET", unnamed.URL+"/?nominal=Router", "")
+	if status != http.StatusBadRequest {
+		t.Errorf("Unexpected status without a nominal field: %v", status)
+	}
+}
+
+func TestHandlerWhere(t *testing.T) {
+	server := newServer(t)
+	defer server.Close()
+
+	for i := 1; i <= 5; i++ {
+		status, contents := call(t, "POST", server.URL+"/devices/", fmt.Sprintf(`{"Name": "Device %v", "Port": %v}`, i, i))
+		if status != http.StatusCreated {
+			t.Fatalf("Error creating device %v: %v", i, contents)
+		}
+	}
+
+	assertList := func(where, expected string) {
+		status, contents := call(t, "GET", server.URL+"/devices/?where="+url.QueryEscape(where), "")
+		if status != http.StatusOK || contents != expected {
+			t.Errorf("%v: got:%v %v, want:%v", where, status, contents, expected)
+		}
+	}
+	assertList(`Port <= 1 OR Name = "Device 5"`, `[{"Id":1,"Name":"Device 1","Port":1},{"Id":5,"Name":"Device 5","Port":5}]`)
+	assertList(`Port IN (2, 3)`, `[{"Id":2,"Name":"Device 2","Port":2},{"Id":3,"Name":"Device 3","Port":3}]`)
+	assertList(`Name = "'; DROP TABLE Device; --"`, `[]`)
+	assertList(`Port > 5`, `[]`)
+
+	status, contents := call(t, "GET", server.URL+"/devices/docs", "")
+	if status != http.StatusOK || !strings.Contains(contents, `"Name":"Port"`) {
+		t.Errorf("Unexpected docs: %v %v", status, contents)
+	}
+}
+
+func TestHandlerDummy(t *testing.T) {
+	server := httptest.NewServer(NewHandler(records.NewDummyService(), &Device{}))
+	defer server.Close()
+
+	status, contents := call(t, "GET", server.URL+"/", "")
+	if status != http.StatusOK || contents != "[]" {
+		t.Errorf("Unexpected list: %v %v", status, contents)
+	}
+	status, _ = call(t, "POST", server.URL+"/", `{"Name": "Router"}`)
+	if status != http.StatusCreated {
+		t.Errorf("Unexpected create status: %v", status)
+	}
+
+	server = httptest.NewServer(NewHandler(records.NewBuggyService(), &Device{}))
+	defer server.Close()
+	status, _ = call(t, "GET", server.URL+"/", "")
+	if status != http.StatusInternalServerError {
+		t.Errorf("Unexpected list status: %v", status)
+	}
+}
@@ -1,6 +1,7 @@
 package records
 
 import (
+	"context"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
 )
 
@@ -10,18 +11,26 @@ This is a dummy service intended for use with testing.  It can be consumed both
 type buggyService struct {
 }
 
-func (service buggyService) createAll(record interface{}) error {
+func (service buggyService) createAll(ctx context.Context, record interface{}) error {
 	return RecordError("Intentional Create Error")
 }
 
-func (service buggyService) readAll(query matcher.Matcher, record ...interface{}) (func(record ...interface{}) bool, error) {
+func (service buggyService) upsertAll(ctx context.Context, record interface{}, key string) error {
+	return RecordError("Intentional Upsert Error")
+}
+
+func (service buggyService) readAll(ctx context.Context, query matcher.Matcher, options QueryOptions, record ...interface{}) (*Cursor, error) {
 	return nil, RecordError("Intentional Read Error")
 }
 
-func (service buggyService) updateAll(record interface{}, match matcher.Matcher) error {
+func (service buggyService) updateAll(ctx context.Context, record interface{}, fields []string, match matcher.Matcher) error {
 	return RecordError("Intentional Update Error")
 }
 
-func (service buggyService) deleteAll(record interface{}, match matcher.Matcher) error {
+func (service buggyService) deleteAll(ctx context.Context, record interface{}, match matcher.Matcher) error {
 	return RecordError("Intentional Delete Error")
 }
+
+func (service buggyService) begin(ctx context.Context) (transaction, error) {
+	return nil, RecordError("Intentional Transaction Error")
+}
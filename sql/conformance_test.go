@@ -0,0 +1,895 @@
+package records
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"testing"
+	"time"
+)
+
+//These are the types used by the conformance suite.  They are defined here so the table names are the same for every service
+type (
+	conformPeer struct {
+		Id   int64 `sql:"primary,autoincrement"`
+		Name string
+	}
+
+	conformDevice struct {
+		Id     int64  `sql:"primary,autoincrement"`
+		PeerId int64  `sql-child:"conformPeer"`
+		Name   string `sql:"unique,nominal"`
+		Port   int64
+	}
+
+	conformLocation struct {
+		DeviceId int64 `sql:"primary" sql-extend:"conformDevice"`
+		Location string
+	}
+
+	conformKinds struct {
+		Id  int64 `sql:"primary,autoincrement"`
+		I   int
+		I8  int8
+		I16 int16
+		I32 int32
+		U   uint
+		U8  uint8
+		U16 uint16
+		U32 uint32
+		U64 uint64
+		F32 float32
+		F64 float64
+		B   bool
+		S   string
+		T   time.Time
+		D   time.Duration
+		P   *int64
+		N   sql.NullString
+	}
+
+	conformTag struct {
+		Id   int32  `sql:"primary,autoincrement"`
+		Name string `sql:"nominal"`
+	}
+
+	conformBadge struct {
+		Id     int64          `sql:"primary,autoincrement"`
+		Code   *int64         `sql:"unique"`
+		Tag    sql.NullString `sql:"unique"`
+		PeerId *int64         `sql-child:"conformPeer" sql-on-delete:"cascade"`
+	}
+)
+
+/*
+This is a test kit for record services.  It runs the same battery of create, read, update, delete, where, join, foreign key and error cases against a service, so that every backend can prove it behaves the same way the sqlite service does.  The factory must return a new, empty service each time it is called
+
+It is kept in a test file, so that the package does not import testing.  A backend test in this package only needs one line
+
+    func TestMyService(t *testing.T) {
+        ConformanceTest(t, newMyService)
+    }
+*/
+func ConformanceTest(t *testing.T, factory func() RecordService) {
+	newService := func(t *testing.T) RecordService {
+		t.Helper()
+		service := factory()
+		if err := service.DefineAll(&conformLocation{}, &conformDevice{}, &conformPeer{}, &conformKinds{}, &conformTag{}, &conformBadge{}); err != nil {
+			t.Fatalf("Could not define the conformance types: %v", err)
+		}
+		return service
+	}
+	seed := func(t *testing.T, service RecordService) {
+		t.Helper()
+		mustCreate(t, service, &conformPeer{Name: "Peer 1"})
+		mustCreate(t, service, &conformPeer{Name: "Peer 2"})
+		for i := int64(1); i <= 5; i++ {
+			mustCreate(t, service, &conformDevice{PeerId: 1 + i%2, Name: fmt.Sprintf("Device %v", i), Port: i * 10})
+		}
+		mustCreate(t, service, &conformLocation{DeviceId: 1, Location: "The kitchen"})
+		mustCreate(t, service, &conformLocation{DeviceId: 2, Location: "The mall"})
+	}
+
+	t.Run("CreateAndRead", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		device := conformDevice{}
+		if err := service.Get(3, &device); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "Get", device, conformDevice{Id: 3, PeerId: 2, Name: "Device 3", Port: 30})
+
+		device = conformDevice{Id: 4}
+		if err := service.Read(&device); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "Read", device, conformDevice{Id: 4, PeerId: 1, Name: "Device 4", Port: 40})
+
+		assertIds(t, service, "ReadAll", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5)
+
+		err := service.CreateAll([]conformDevice{{PeerId: 1, Name: "Device 6"}, {PeerId: 1, Name: "Device 7"}})
+		if err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "CreateAll", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5, 6, 7)
+	})
+
+	t.Run("Kinds", func(t *testing.T) {
+		service := newService(t)
+		pointed := int64(-7)
+		expected := []conformKinds{
+			{Id: 1, I: -1, I8: -8, I16: -16, I32: -32, U: 1, U8: 255, U16: 16, U32: 32, U64: 64, F32: 0.5, F64: -1.25, B: true, S: `It's "quoted"`, T: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), D: 90 * time.Second, P: &pointed, N: sql.NullString{String: "Nick", Valid: true}},
+			{Id: 2, S: "'); DROP TABLE conformKinds; --"},
+		}
+		for _, record := range expected {
+			record.Id = 0
+			mustCreate(t, service, &record)
+		}
+		cursor, err := service.ReadAll(&conformKinds{}, QueryOptions{OrderBy: []Order{Asc("Id")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make([]conformKinds, 0)
+		temp := conformKinds{}
+		for cursor.Next() {
+			if err := cursor.Scan(&temp); err != nil {
+				t.Error(err)
+			}
+			found = append(found, temp)
+		}
+		if err := cursor.Err(); err != nil {
+			t.Error(err)
+		}
+		//Pointers print as addresses, so they are compared by what they point to and then shared
+		for i := range found {
+			if i < len(expected) && fmt.Sprint(deref(found[i].P)) == fmt.Sprint(deref(expected[i].P)) {
+				found[i].P = expected[i].P
+			}
+		}
+		assertEqual(t, "Kinds", found, expected)
+
+		for _, record := range expected {
+			match := matcher.NewStructMatcher()
+			match.AddField("S", matcher.Eq(record.S))
+			match.AddField("B", matcher.Eq(record.B))
+			match.AddField("U8", matcher.Eq(record.U8))
+			match.AddField("T", matcher.Eq(record.T))
+			match.AddField("D", matcher.Eq(record.D))
+			assertKindIds(t, service, "Where "+record.S, match, record.Id)
+		}
+
+		//Times compare in time order, whatever their location, and a time in another location is the same time
+		at := func(op func(interface{}) matcher.Matcher, value interface{}) matcher.Matcher {
+			match := matcher.NewStructMatcher()
+			match.AddField("T", op(value))
+			return match
+		}
+		later := expected[0].T.Add(time.Nanosecond).In(time.FixedZone("East", 5*60*60))
+		assertKindIds(t, service, "Time before", at(matcher.Lt, later), 1, 2)
+		assertKindIds(t, service, "Time after", at(matcher.Gt, expected[0].T.Add(-time.Nanosecond)), 1)
+		assertKindIds(t, service, "Time in another zone", at(matcher.Eq, expected[0].T.In(time.FixedZone("West", -8*60*60))), 1)
+		assertKindIds(t, service, "Time in", at(matcher.In, []time.Time{expected[0].T, later}), 1)
+		assertKindIds(t, service, "Relative time", at(matcher.Gt, matcher.Now(-time.Hour)))
+		duration := matcher.NewStructMatcher()
+		duration.AddField("D", matcher.Gte(time.Minute))
+		assertKindIds(t, service, "Duration", duration, 1)
+
+		//NULL is only found by IS NULL, and a comparison with it does not match either way
+		where := func(name string, m matcher.Matcher) matcher.Matcher {
+			s := matcher.NewStructMatcher()
+			s.AddField(name, m)
+			return s
+		}
+		assertKindIds(t, service, "Pointer is null", where("P", matcher.IsNull()), 2)
+		assertKindIds(t, service, "Pointer is not null", where("P", matcher.IsNotNull()), 1)
+		assertKindIds(t, service, "Pointer equal", where("P", matcher.Eq(pointed)), 1)
+		assertKindIds(t, service, "Pointer not equal", where("P", matcher.Neq(int64(0))), 1)
+		assertKindIds(t, service, "Pointer not in", where("P", matcher.NotIn([]int64{0})), 1)
+		assertKindIds(t, service, "Null type is null", where("N", matcher.IsNull()), 2)
+		assertKindIds(t, service, "Null type equal", where("N", matcher.Eq("Nick")), 1)
+		assertKindIds(t, service, "Null type not equal", where("N", matcher.Not(matcher.Eq("Nick"))))
+
+		sorted, err := service.ReadAll(&conformKinds{}, QueryOptions{OrderBy: []Order{Desc("T")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int64, 0)
+		for sorted.Next() {
+			if err := sorted.Scan(&temp); err != nil {
+				t.Error(err)
+			}
+			ids = append(ids, temp.Id)
+		}
+		assertEqual(t, "Order by time", ids, []int64{1, 2})
+
+		sorted, err = service.ReadAll(&conformKinds{}, QueryOptions{OrderBy: []Order{Asc("P")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = make([]int64, 0)
+		for sorted.Next() {
+			if err := sorted.Scan(&temp); err != nil {
+				t.Error(err)
+			}
+			ids = append(ids, temp.Id)
+		}
+		assertEqual(t, "NULL orders first", ids, []int64{2, 1})
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		service := newService(t)
+		code := int64(7)
+		badge := conformBadge{Code: &code}
+		mustCreate(t, service, &badge)
+		//NULL is never equal to NULL, so it may repeat in a unique field
+		mustCreate(t, service, &conformBadge{})
+		mustCreate(t, service, &conformBadge{})
+		same := int64(7)
+		if err := service.Create(&conformBadge{Code: &same}); err == nil {
+			t.Error("Duplicate unique held by another pointer: expected an error")
+		}
+		mustCreate(t, service, &conformBadge{Tag: sql.NullString{String: "a", Valid: true}})
+		if err := service.Create(&conformBadge{Tag: sql.NullString{String: "a", Valid: true}}); err == nil {
+			t.Error("Duplicate unique sql.NullString: expected an error")
+		}
+
+		//The stored value is not shared with the record it came from, or the record it is read into
+		code = 8
+		read := conformBadge{Id: badge.Id}
+		if err := service.Read(&read); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "Pointer kept its value", deref(read.Code), int64(7))
+		*read.Code = 9
+		again := conformBadge{Id: badge.Id}
+		if err := service.Read(&again); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "Pointer read back", deref(again.Code), int64(7))
+
+		updated := int64(10)
+		if err := service.Update(&conformBadge{Id: badge.Id, Code: &updated}); err != nil {
+			t.Fatal(err)
+		}
+		updated = 11
+		if err := service.Read(&again); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, "Pointer updated", deref(again.Code), int64(10))
+
+		//A NULL foreign key points to nothing, so it is neither checked nor deleted with a parent
+		mustCreate(t, service, &conformPeer{Name: "Peer 1"})
+		peer, missing := int64(1), int64(9)
+		mustCreate(t, service, &conformBadge{PeerId: &peer})
+		if err := service.Create(&conformBadge{PeerId: &missing}); err == nil {
+			t.Error("Missing parent through a pointer: expected an error")
+		}
+		if err := service.DeleteById(1, &conformPeer{}); err != nil {
+			t.Fatal(err)
+		}
+		cursor, err := service.ReadAll(&conformBadge{}, QueryOptions{OrderBy: []Order{Asc("Id")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int64, 0)
+		for cursor.Next() {
+			if err := cursor.Scan(&again); err != nil {
+				t.Error(err)
+			}
+			ids = append(ids, again.Id)
+		}
+		if err := cursor.Err(); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "Cascade through a pointer", ids, []int64{1, 2, 3, 4})
+	})
+
+	t.Run("Where", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		where := func(name string, m matcher.Matcher) matcher.Matcher {
+			s := matcher.NewStructMatcher()
+			s.AddField(name, m)
+			return s
+		}
+		assertIds(t, service, "Eq", where("Name", matcher.Eq("Device 2")), QueryOptions{}, 2)
+		assertIds(t, service, "Neq", where("Port", matcher.Neq(int64(20))), QueryOptions{}, 1, 3, 4, 5)
+		assertIds(t, service, "Lt", where("Port", matcher.Lt(int64(30))), QueryOptions{}, 1, 2)
+		assertIds(t, service, "Gte", where("Port", matcher.Gte(int64(30))), QueryOptions{}, 3, 4, 5)
+		assertIds(t, service, "In", where("Name", matcher.In([]string{"Device 1", "Device 5", "Nothing"})), QueryOptions{}, 1, 5)
+		assertIds(t, service, "NotIn", where("Id", matcher.NotIn([]int64{1, 2})), QueryOptions{}, 3, 4, 5)
+		assertIds(t, service, "And", matcher.And(where("PeerId", matcher.Eq(int64(2))), where("Port", matcher.Gt(int64(10)))), QueryOptions{}, 3, 5)
+		assertIds(t, service, "Or", matcher.Or(where("Id", matcher.Eq(int64(1))), where("Port", matcher.Gt(int64(40)))), QueryOptions{}, 1, 5)
+		assertIds(t, service, "Not", matcher.Not(matcher.And(where("Id", matcher.Gt(int64(1))), where("Id", matcher.Lt(int64(5))))), QueryOptions{}, 1, 5)
+		assertIds(t, service, "None", matcher.None(), QueryOptions{})
+
+		field := matcher.NewStructMatcher()
+		field.AddField("PeerId", matcher.Lt(field.Field("Id")))
+		assertIds(t, service, "Field", field, QueryOptions{}, 2, 3, 4, 5)
+
+		//Patterns ignore case, and a prefix or substring is matched literally, even when it holds the characters LIKE treats specially
+		mustCreate(t, service, &conformDevice{PeerId: 1, Name: "Dev_ 100%"})
+		assertIds(t, service, "Like", where("Name", matcher.Like("device _")), QueryOptions{}, 1, 2, 3, 4, 5)
+		assertIds(t, service, "NotLike", where("Name", matcher.NotLike("Device 1%")), QueryOptions{}, 2, 3, 4, 5, 6)
+		assertIds(t, service, "StartsWith", where("Name", matcher.StartsWith("dev_")), QueryOptions{}, 6)
+		assertIds(t, service, "Contains", where("Name", matcher.Contains("100%")), QueryOptions{}, 6)
+		assertIds(t, service, "NotContains", where("Name", matcher.NotContains("0%")), QueryOptions{}, 1, 2, 3, 4, 5)
+		assertIds(t, service, "Ieq", where("Name", matcher.Ieq("DEVICE 3")), QueryOptions{}, 3)
+		assertIds(t, service, "NotIeq", where("Name", matcher.NotIeq("device 3")), QueryOptions{}, 1, 2, 4, 5, 6)
+		assertIds(t, service, "Between", where("Port", matcher.Between(int64(20), int64(40))), QueryOptions{}, 2, 3, 4)
+		assertIds(t, service, "NotBetween", matcher.Not(where("Port", matcher.Between(int64(20), int64(40)))), QueryOptions{}, 1, 5, 6)
+
+		//Only ASCII letters are folded, as sqlite does, so other letters must match their case
+		mustCreate(t, service, &conformDevice{PeerId: 1, Name: "Équipe"})
+		assertIds(t, service, "Ieq keeps other letters", where("Name", matcher.Ieq("ÉQUIPE")), QueryOptions{}, 7)
+		assertIds(t, service, "Ieq does not fold other letters", where("Name", matcher.Ieq("équipe")), QueryOptions{})
+		assertIds(t, service, "Like does not fold other letters", where("Name", matcher.Like("é%")), QueryOptions{})
+		assertIds(t, service, "StartsWith does not fold other letters", where("Name", matcher.StartsWith("éq")), QueryOptions{})
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		assertIds(t, service, "Order", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("PeerId"), Desc("Port")}}, 4, 2, 5, 3, 1)
+		assertIds(t, service, "Limit", matcher.Any(), QueryOptions{OrderBy: []Order{Desc("Name")}, Limit: 2}, 5, 4)
+		assertIds(t, service, "Offset", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("Id")}, Limit: 2, Offset: 2}, 3, 4)
+		assertIds(t, service, "After", matcher.Any(), QueryOptions{After: int64(3)}, 4, 5)
+		assertIds(t, service, "After descending", matcher.Any(), QueryOptions{After: int64(3), OrderBy: []Order{Desc("Id")}}, 2, 1)
+		//The cursor holds only the primary key, so it cannot page through any other order
+		for _, orders := range [][]Order{{Asc("Port")}, {Asc("Id"), Asc("Port")}} {
+			if _, err := service.ReadAll(&conformDevice{}, QueryOptions{After: int64(3), OrderBy: orders}); err == nil {
+				t.Errorf("Expected After ordered by %v to be an error", orders)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		device := conformDevice{}
+		service.Get(2, &device)
+		device.Name = "Renamed"
+		device.Port = 99
+		if err := service.Update(&device); err != nil {
+			t.Error(err)
+		}
+		device = conformDevice{}
+		service.Get(2, &device)
+		assertEqual(t, "Update", device, conformDevice{Id: 2, PeerId: 1, Name: "Renamed", Port: 99})
+
+		location := conformLocation{DeviceId: 1, Location: "The garage"}
+		if err := service.Update(&location); err != nil {
+			t.Error(err)
+		}
+		location = conformLocation{DeviceId: 1}
+		service.Read(&location)
+		assertEqual(t, "Update without autoincrement", location, conformLocation{DeviceId: 1, Location: "The garage"})
+
+		match := matcher.NewStructMatcher()
+		match.AddField("DeviceId", matcher.Gt(int64(100)))
+		if err := service.UpdateAllWhere(&conformLocation{DeviceId: 1, Location: "Nowhere"}, match); err != nil {
+			t.Error(err)
+		}
+		service.Read(&location)
+		assertEqual(t, "Update matching nothing", location, conformLocation{DeviceId: 1, Location: "The garage"})
+
+		match = matcher.NewStructMatcher()
+		match.AddField("DeviceId", matcher.Eq(int64(1)))
+		if err := service.UpdateAllWhere(&conformLocation{DeviceId: 9, Location: "The porch"}, match); err != nil {
+			t.Error(err)
+		}
+		service.Read(&location)
+		assertEqual(t, "Update leaves the primary key alone", location, conformLocation{DeviceId: 1, Location: "The porch"})
+
+		if err := service.UpdateFields(&conformDevice{Id: 3, Name: "Ignored", Port: 33}, "Port"); err != nil {
+			t.Error(err)
+		}
+		service.Get(3, &device)
+		assertEqual(t, "UpdateFields", device, conformDevice{Id: 3, PeerId: 2, Name: "Device 3", Port: 33})
+		err := service.UpdateFields(&conformDevice{Id: 3, Port: 34}, "Port", "Id")
+		if _, ok := err.(ImmutableFieldError); !ok {
+			t.Errorf("UpdateFields of the primary key: expected an ImmutableFieldError, got %v", err)
+		}
+		if err = service.UpdateFields(&conformDevice{Id: 3}, "Bacon"); err == nil {
+			t.Error("UpdateFields of an unknown field: expected an error")
+		}
+		service.Get(3, &device)
+		assertEqual(t, "Failed UpdateFields are not applied", device.Port, int64(33))
+
+		match = matcher.NewStructMatcher()
+		match.AddField("PeerId", matcher.Eq(int64(2)))
+		if err := service.UpdateAllWhere(Set(&conformDevice{}, map[string]interface{}{"Port": 7}), match); err != nil {
+			t.Error(err)
+		}
+		service.Get(5, &device)
+		assertEqual(t, "UpdateAllWhere with a map", device, conformDevice{Id: 5, PeerId: 2, Name: "Device 5", Port: 7})
+		if err := service.Update(Only(&conformDevice{Id: 5, Name: "Device 5b", Port: 8}, "Name")); err != nil {
+			t.Error(err)
+		}
+		service.Get(5, &device)
+		assertEqual(t, "Update with a field mask", device, conformDevice{Id: 5, PeerId: 2, Name: "Device 5b", Port: 7})
+		if err := service.UpdateAll(Set(&conformDevice{}, map[string]interface{}{"Port": "seven"})); err == nil {
+			t.Error("UpdateAll with a value of the wrong kind: expected an error")
+		}
+		if err := service.UpdateAll(Set(&conformDevice{}, map[string]interface{}{"Id": 1})); err == nil {
+			t.Error("UpdateAll of the primary key with a map: expected an error")
+		}
+		match = matcher.NewStructMatcher()
+		match.AddField("Port", matcher.Eq(int64(7)))
+		assertIds(t, service, "Failed patches are not applied", match, QueryOptions{}, 1, 3, 5)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		if err := service.Delete(&conformDevice{Id: 1}); err != nil {
+			t.Error(err)
+		}
+		if err := service.DeleteById(2, &conformDevice{}); err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "Delete", matcher.Any(), QueryOptions{}, 3, 4, 5)
+
+		match := matcher.NewStructMatcher()
+		match.AddField("Port", matcher.Gte(int64(40)))
+		if err := service.DeleteAllWhere(&conformDevice{}, match); err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "DeleteAllWhere", matcher.Any(), QueryOptions{}, 3)
+
+		if err := service.DeleteAll(&conformDevice{}); err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "DeleteAll", matcher.Any(), QueryOptions{})
+
+		mustCreate(t, service, &conformDevice{PeerId: 1, Name: "Device 6"})
+		assertIds(t, service, "Autoincrement is not reused", matcher.Any(), QueryOptions{}, 6)
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		devices := []conformDevice{{PeerId: 1, Name: "Device 6", Port: 60}, {PeerId: 2, Name: "Device 7", Port: 70}}
+		if err := service.CreateAll(devices); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "CreateAll writes back the generated keys", []int64{devices[0].Id, devices[1].Id}, []int64{6, 7})
+		pointers := []*conformPeer{{Name: "Peer 3"}, {Name: "Peer 4"}}
+		if err := service.CreateAll(pointers); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "CreateAll writes back through pointers", []int64{pointers[0].Id, pointers[1].Id}, []int64{3, 4})
+		peer := conformPeer{Name: "Peer 5"}
+		if err := service.Create(&peer); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "Create writes back the generated key", peer.Id, int64(5))
+		if err := service.Create(conformPeer{Name: "Peer 6"}); err == nil {
+			t.Error("Create by value with a generated key: expected an error")
+		}
+
+		devices = []conformDevice{{Id: 2, PeerId: 2, Name: "Device 2b", Port: 21}, {PeerId: 1, Name: "Device 8", Port: 80}}
+		if err := service.UpsertAll(devices); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "UpsertAll writes back the generated keys", devices[1].Id, int64(8))
+		device := conformDevice{}
+		service.Get(2, &device)
+		assertEqual(t, "UpsertAll updates by primary key", device, conformDevice{Id: 2, PeerId: 2, Name: "Device 2b", Port: 21})
+
+		location := conformLocation{DeviceId: 1, Location: "The attic"}
+		if err := service.Upsert(&location); err != nil {
+			t.Error(err)
+		}
+		location = conformLocation{DeviceId: 1}
+		service.Read(&location)
+		assertEqual(t, "Upsert updates an extension", location, conformLocation{DeviceId: 1, Location: "The attic"})
+
+		if err := service.UpsertBy("Port", &conformDevice{Port: 10}); err == nil {
+			t.Error("Upsert by a field that is not unique: expected an error")
+		}
+		if err := service.UpsertAll([]conformDevice{{Id: 4, PeerId: 1, Name: "Device 4"}, {Id: 5, PeerId: 9, Name: "Device 5"}}); err == nil {
+			t.Error("Upsert with a missing parent: expected an error")
+		}
+		service.Get(4, &device)
+		assertEqual(t, "Failed upserts are not applied", device, conformDevice{Id: 4, PeerId: 1, Name: "Device 4", Port: 40})
+
+		batched := service.WithBatchSize(2)
+		devices = make([]conformDevice, 0)
+		for i := 9; i < 14; i++ {
+			devices = append(devices, conformDevice{PeerId: 1, Name: fmt.Sprintf("Device %v", i)})
+		}
+		if err := batched.CreateAll(devices); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "Batches write back the generated keys", []int64{devices[0].Id, devices[4].Id}, []int64{9, 13})
+		err := batched.CreateAll([]conformDevice{{PeerId: 1, Name: "Device 14"}, {PeerId: 1, Name: "Device 15"}, {PeerId: 1, Name: "Device 1"}})
+		if err == nil {
+			t.Error("Duplicate unique in a later batch: expected an error")
+		}
+		assertIds(t, service, "Failed batches are not applied", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13)
+
+		devices = []conformDevice{{PeerId: 1, Name: "Device 3", Port: 33}, {PeerId: 2, Name: "Device 20", Port: 200}}
+		if err := service.UpsertAllBy("Name", devices); err != nil {
+			t.Error(err)
+		}
+		//Databases may use up a generated key on a row that is updated instead, so only the existing key is known
+		assertEqual(t, "UpsertAllBy reads back the keys", devices[0].Id, int64(3))
+		if err := service.GetByNominal("Device 20", &device); err != nil || device.Id != devices[1].Id {
+			t.Errorf("UpsertAllBy reads back the generated keys: got:%v, want:%v", devices[1].Id, device.Id)
+		}
+		service.Get(3, &device)
+		assertEqual(t, "UpsertAllBy updates by unique field", device, conformDevice{Id: 3, PeerId: 1, Name: "Device 3", Port: 33})
+	})
+
+	t.Run("ForeignKeys", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		assertError := func(message string, err error) {
+			t.Helper()
+			if err == nil {
+				t.Errorf("%v: expected an error", message)
+			}
+		}
+		assertError("Missing parent", service.Create(&conformDevice{PeerId: 9, Name: "Device 6"}))
+		assertError("Missing parent in batch", service.CreateAll([]conformDevice{{PeerId: 1, Name: "Device 6"}, {PeerId: 9, Name: "Device 7"}}))
+		assertError("Missing extended record", service.Create(&conformLocation{DeviceId: 9}))
+		assertError("Missing parent on update", service.Update(&conformDevice{Id: 3, PeerId: 9, Name: "Device 3"}))
+		assertError("Restricted delete", service.DeleteById(1, &conformPeer{}))
+		assertIds(t, service, "Failed writes are not applied", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5)
+		device := conformDevice{}
+		service.Get(3, &device)
+		assertEqual(t, "Failed update is not applied", device.PeerId, int64(2))
+
+		if err := service.Delete(&conformDevice{Id: 1}); err != nil {
+			t.Error(err)
+		}
+		cursor, err := service.ReadAll(&conformLocation{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		locations := make([]conformLocation, 0)
+		for cursor.Next() {
+			location := conformLocation{}
+			cursor.Scan(&location)
+			locations = append(locations, location)
+		}
+		assertEqual(t, "Extensions are deleted with the record they extend", locations, []conformLocation{{DeviceId: 2, Location: "The mall"}})
+
+		match := matcher.NewStructMatcher()
+		match.AddField("PeerId", matcher.Eq(int64(1)))
+		if err := service.DeleteAllWhere(&conformDevice{}, match); err != nil {
+			t.Error(err)
+		}
+		if err := service.DeleteById(1, &conformPeer{}); err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "Delete once the children are gone", matcher.Any(), QueryOptions{}, 3, 5)
+	})
+
+	t.Run("Nominal", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		mustCreate(t, service, &conformDevice{PeerId: 1, Name: "Router"})
+		nominals, err := service.ReadAllNominal(&conformDevice{})
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "ReadAllNominal", nominals, []Nominal{{1, "Device 1"}, {2, "Device 2"}, {3, "Device 3"}, {4, "Device 4"}, {5, "Device 5"}, {6, "Router"}})
+
+		where := matcher.NewStructMatcher()
+		where.AddField("PeerId", matcher.Eq(int64(1)))
+		nominals, err = service.ReadAllNominalWhere(&conformDevice{}, where, QueryOptions{OrderBy: []Order{Desc("Name")}})
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "ReadAllNominalWhere", nominals, []Nominal{{6, "Router"}, {4, "Device 4"}, {2, "Device 2"}})
+
+		nominals, err = service.SearchNominal(&conformDevice{}, "Dev", where)
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "SearchNominal", nominals, []Nominal{{2, "Device 2"}, {4, "Device 4"}})
+		nominals, err = service.SearchNominal(&conformDevice{}, "R", matcher.Any())
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "SearchNominal without a filter", nominals, []Nominal{{6, "Router"}})
+		nominals, err = service.SearchNominal(&conformDevice{}, "dEVICE 1", matcher.Any())
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "SearchNominal ignores case", nominals, []Nominal{{1, "Device 1"}})
+		nominals, err = service.SearchNominal(&conformDevice{}, "Device_", matcher.Any())
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "SearchNominal prefix is literal", nominals, []Nominal{})
+
+		nominal, err := service.GetNominal(3, &conformDevice{})
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "GetNominal", nominal, Nominal{3, "Device 3"})
+		if _, err = service.GetNominal(9, &conformDevice{}); err == nil {
+			t.Error("GetNominal of a missing record: expected an error")
+		}
+		//The id is converted to the type of the primary key
+		mustCreate(t, service, &conformTag{Name: "Tag 1"})
+		nominal, err = service.GetNominal(1, &conformTag{})
+		if err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "GetNominal with an int32 key", nominal, Nominal{1, "Tag 1"})
+		if _, err = service.GetNominal(1<<40, &conformTag{}); err == nil {
+			t.Error("GetNominal with an id too large for the key: expected an error")
+		}
+
+		device := conformDevice{}
+		if err = service.GetByNominal("Device 5", &device); err != nil {
+			t.Error(err)
+		}
+		assertEqual(t, "GetByNominal", device, conformDevice{Id: 5, PeerId: 2, Name: "Device 5", Port: 50})
+		if err = service.GetByNominal("Device 9", &conformDevice{}); err == nil {
+			t.Error("GetByNominal of a missing record: expected an error")
+		}
+	})
+
+	t.Run("Join", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		device := conformDevice{}
+		location := conformLocation{}
+		peer := conformPeer{}
+
+		assertJoin := func(message string, expected string, match matcher.Matcher, records ...interface{}) {
+			t.Helper()
+			cursor, err := service.delegate.readAll(context.Background(), match, QueryOptions{OrderBy: []Order{Asc("Id")}}, records...)
+			if err != nil {
+				t.Errorf("%v: %v", message, err)
+				return
+			}
+			found := make([]string, 0)
+			for cursor.Next() {
+				if err := cursor.Scan(records...); err != nil {
+					t.Error(err)
+				}
+				row := make([]interface{}, 0)
+				for _, record := range records {
+					row = append(row, fmt.Sprint(record))
+				}
+				found = append(found, fmt.Sprint(row...))
+			}
+			if err := cursor.Err(); err != nil {
+				t.Errorf("%v: %v", message, err)
+			}
+			assertEqual(t, message, fmt.Sprint(found), expected)
+		}
+		assertJoin("Extend", "[&{1 2 Device 1 10}&{1 The kitchen} &{2 1 Device 2 20}&{2 The mall}]", matcher.Any(), &device, &location)
+		assertJoin("Child", "[&{1 2 Device 1 10}&{2 Peer 2} &{2 1 Device 2 20}&{1 Peer 1} &{3 2 Device 3 30}&{2 Peer 2} &{4 1 Device 4 40}&{1 Peer 1} &{5 2 Device 5 50}&{2 Peer 2}]", matcher.Any(), &device, &peer)
+
+		//Paths pick a table, which matters when the joined records share a field name
+		path := func(name string, match matcher.Matcher) matcher.Matcher {
+			output := matcher.NewStructMatcher()
+			output.AddField(name, match)
+			return output
+		}
+		assertJoin("Path", "[&{2 1 Device 2 20}&{1 Peer 1} &{4 1 Device 4 40}&{1 Peer 1}]", path("conformPeer.Name", matcher.Eq("Peer 1")), &device, &peer)
+		assertJoin("Shared name", "[&{1 2 Device 1 10}&{2 Peer 2}]", matcher.And(path("Name", matcher.Eq("Device 1")), path("conformPeer.Id", matcher.Eq(int64(2)))), &device, &peer)
+		assertJoin("Extension path", "[&{2 1 Device 2 20}&{2 The mall}]", path("conformLocation.Location", matcher.Eq("The mall")), &device, &location)
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		failure := RecordError("Intentional failure")
+
+		err := service.Transaction(func(tx RecordService) error {
+			mustCreate(t, tx, &conformDevice{PeerId: 1, Name: "Device 6"})
+			return tx.DeleteById(1, &conformDevice{})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "Commit", matcher.Any(), QueryOptions{}, 2, 3, 4, 5, 6)
+
+		err = service.Transaction(func(tx RecordService) error {
+			mustCreate(t, tx, &conformDevice{PeerId: 1, Name: "Device 7"})
+			assertIds(t, tx, "Uncommitted writes are visible in the transaction", matcher.Any(), QueryOptions{}, 2, 3, 4, 5, 6, 7)
+			tx.DeleteAll(&conformDevice{})
+			return failure
+		})
+		assertEqual(t, "Callback error is returned", err, failure)
+		assertIds(t, service, "Rollback on error", matcher.Any(), QueryOptions{}, 2, 3, 4, 5, 6)
+
+		func() {
+			defer func() {
+				assertEqual(t, "Panic is passed on", recover(), "bacon")
+			}()
+			service.Transaction(func(tx RecordService) error {
+				tx.DeleteAll(&conformDevice{})
+				panic("bacon")
+			})
+		}()
+		assertIds(t, service, "Rollback on panic", matcher.Any(), QueryOptions{}, 2, 3, 4, 5, 6)
+
+		err = service.Transaction(func(tx RecordService) error {
+			tx.DeleteById(2, &conformDevice{})
+			inner := tx.Transaction(func(tx RecordService) error {
+				tx.DeleteById(3, &conformDevice{})
+				return failure
+			})
+			assertEqual(t, "Nested error is returned", inner, failure)
+			return tx.Transaction(func(tx RecordService) error {
+				return tx.DeleteById(4, &conformDevice{})
+			})
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		assertIds(t, service, "Nested rollback", matcher.Any(), QueryOptions{}, 3, 5, 6)
+
+		err = service.Transaction(func(tx RecordService) error {
+			tx.DeleteById(5, &conformDevice{})
+			return tx.Create(&conformLocation{DeviceId: 1})
+		})
+		if err == nil {
+			t.Error("Expected the constraint error to be returned")
+		}
+		assertIds(t, service, "Failed operation", matcher.Any(), QueryOptions{}, 3, 5, 6)
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		cursor, err := service.ReadAllWhereContext(ctx, &conformDevice{}, matcher.Any(), QueryOptions{OrderBy: []Order{Asc("Id")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		device := conformDevice{}
+		found := make([]int64, 0)
+		for cursor.Next() {
+			if err := cursor.Scan(&device); err != nil {
+				t.Error(err)
+			}
+			found = append(found, device.Id)
+			cancel()
+		}
+		assertEqual(t, "Iteration stops when the context is done", found, []int64{1})
+		assertEqual(t, "Cursor error", cursor.Err(), context.Canceled)
+
+		assertCancelled := func(message string, err error) {
+			t.Helper()
+			if err != context.Canceled {
+				t.Errorf("%v: got:%v, want:%v", message, err, context.Canceled)
+			}
+		}
+		_, err = service.ReadAllWhereContext(ctx, &conformDevice{}, matcher.Any())
+		assertCancelled("Read", err)
+		assertCancelled("Create", service.CreateContext(ctx, &conformDevice{PeerId: 1, Name: "Device 6"}))
+		assertCancelled("Update", service.UpdateContext(ctx, &conformDevice{Id: 1}))
+		assertCancelled("Delete", service.DeleteContext(ctx, &conformDevice{Id: 1}))
+		assertCancelled("Transaction", service.TransactionContext(ctx, func(tx RecordService) error {
+			t.Error("The callback should not be called")
+			return nil
+		}))
+		assertIds(t, service, "Cancelled operations are not applied", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		service := newService(t)
+		seed(t, service)
+		assertError := func(message string, err error) {
+			t.Helper()
+			if err == nil {
+				t.Errorf("%v: expected an error", message)
+			}
+		}
+
+		assertError("Duplicate primary", service.Create(&conformLocation{DeviceId: 1}))
+		assertError("Duplicate primary in batch", service.CreateAll([]conformLocation{{DeviceId: 8}, {DeviceId: 8}}))
+		location := conformLocation{DeviceId: 8}
+		service.Read(&location)
+		assertEqual(t, "Failed creates are not applied", location, conformLocation{DeviceId: 8})
+
+		assertError("Duplicate unique", service.Create(&conformDevice{Name: "Device 1"}))
+		assertError("Duplicate unique in batch", service.CreateAll([]conformDevice{{Name: "Device 8"}, {Name: "Device 8"}}))
+		assertError("Duplicate unique on update", service.Update(&conformDevice{Id: 2, PeerId: 1, Name: "Device 3"}))
+		assertError("Duplicate unique on update all", service.UpdateAll(&conformDevice{Name: "Same"}))
+		device := conformDevice{}
+		service.Get(2, &device)
+		assertEqual(t, "Failed updates are not applied", device, conformDevice{Id: 2, PeerId: 1, Name: "Device 2", Port: 20})
+
+		type undefined struct {
+			Id int64 `sql:"primary"`
+		}
+		assertError("Create undefined", service.Create(&undefined{}))
+		_, err := service.ReadAll(&undefined{})
+		assertError("Read undefined", err)
+		assertError("Update undefined", service.Update(&undefined{}))
+		assertError("Delete undefined", service.Delete(&undefined{}))
+		type unknownOnDelete struct {
+			Id     int64 `sql:"primary"`
+			PeerId int64 `sql-child:"conformPeer" sql-on-delete:"set null"`
+		}
+		assertError("Define with an unknown sql-on-delete", service.Define(&unknownOnDelete{}))
+
+		_, err = service.ReadAllWhere(&conformDevice{}, matcher.Buggy())
+		assertError("Read with a broken matcher", err)
+		assertError("Update with a broken matcher", service.UpdateAllWhere(&conformDevice{}, matcher.Buggy()))
+		assertError("Delete with a broken matcher", service.DeleteAllWhere(&conformDevice{}, matcher.Buggy()))
+		_, err = service.ReadAll(&conformDevice{}, QueryOptions{OrderBy: []Order{Asc("Bacon")}})
+		assertError("Order by an unknown field", err)
+		assertIds(t, service, "Failed deletes are not applied", matcher.Any(), QueryOptions{}, 1, 2, 3, 4, 5)
+	})
+}
+
+func mustCreate(t *testing.T, service RecordService, record interface{}) {
+	t.Helper()
+	if err := service.Create(record); err != nil {
+		t.Fatalf("Could not create %v: %v", record, err)
+	}
+}
+
+func deref(p *int64) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func assertEqual(t *testing.T, message string, found, expected interface{}) {
+	t.Helper()
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		t.Errorf("%v: got:%v, want:%v", message, found, expected)
+	}
+}
+
+func assertIds(t *testing.T, service RecordService, message string, match matcher.Matcher, options QueryOptions, expected ...int64) {
+	t.Helper()
+	if options.OrderBy == nil {
+		options.OrderBy = []Order{Asc("Id")}
+	}
+	cursor, err := service.ReadAllWhere(&conformDevice{}, match, options)
+	if err != nil {
+		t.Errorf("%v: %v", message, err)
+		return
+	}
+	found := make([]int64, 0)
+	temp := conformDevice{}
+	for cursor.Next() {
+		if err := cursor.Scan(&temp); err != nil {
+			t.Error(err)
+		}
+		found = append(found, temp.Id)
+	}
+	if err := cursor.Err(); err != nil {
+		t.Errorf("%v: %v", message, err)
+	}
+	assertEqual(t, message, found, expected)
+}
+
+func assertKindIds(t *testing.T, service RecordService, message string, match matcher.Matcher, expected ...int64) {
+	t.Helper()
+	cursor, err := service.ReadAllWhere(&conformKinds{}, match)
+	if err != nil {
+		t.Errorf("%v: %v", message, err)
+		return
+	}
+	found := make([]int64, 0)
+	temp := conformKinds{}
+	for cursor.Next() {
+		if err := cursor.Scan(&temp); err != nil {
+			t.Error(err)
+		}
+		found = append(found, temp.Id)
+	}
+	if err := cursor.Err(); err != nil {
+		t.Errorf("%v: %v", message, err)
+	}
+	assertEqual(t, message, found, expected)
+}
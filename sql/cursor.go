@@ -0,0 +1,148 @@
+package records
+
+import (
+	"fmt"
+	"reflect"
+)
+
+/*
+This is the part of a result set each service provides.  A Cursor takes care of the bookkeeping around it
+*/
+type rowSource interface {
+	next() bool
+	scan(records ...interface{}) error
+	err() error
+	close() error
+}
+
+/*
+This is the result of a read.  It follows the same pattern as sql.Rows
+
+    cursor, err := service.ReadAll(&device)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close()
+    for cursor.Next() {
+        err = cursor.Scan(&device)
+        if err != nil {
+            return err
+        }
+    }
+    return cursor.Err()
+
+The cursor closes itself once Next returns false.  Close must be called if you stop early, or the underlying result set is leaked
+*/
+type Cursor struct {
+	source  rowSource
+	hasRow  bool
+	closed  bool
+	lastErr error
+}
+
+func newCursor(source rowSource) *Cursor {
+	return &Cursor{source: source}
+}
+
+/*
+This advances the cursor to the next row, and reports if there is one
+*/
+func (cursor *Cursor) Next() bool {
+	if cursor.closed {
+		return false
+	}
+	cursor.hasRow = cursor.source.next()
+	if !cursor.hasRow {
+		cursor.lastErr = cursor.source.err()
+		cursor.Close()
+	}
+	return cursor.hasRow
+}
+
+/*
+This copies the current row into the records, which must be pointers to the record types that were read
+*/
+func (cursor *Cursor) Scan(records ...interface{}) error {
+	if cursor.closed {
+		return RecordError("Cursor is closed")
+	}
+	if !cursor.hasRow {
+		return RecordError("Scan called without calling Next")
+	}
+	return cursor.source.scan(records...)
+}
+
+/*
+This returns the error, if any, that ended the iteration early
+*/
+func (cursor *Cursor) Err() error {
+	return cursor.lastErr
+}
+
+/*
+This releases the result set.  It is safe to call more than once
+*/
+func (cursor *Cursor) Close() error {
+	if cursor.closed {
+		return nil
+	}
+	cursor.closed = true
+	cursor.hasRow = false
+	return cursor.source.close()
+}
+
+/*
+This adapts a cursor to the closure style returned by earlier versions of ReadAllWhere.  The closure scans each row into the records it is passed, and returns false once there are no more rows.  It stops at the first Scan error.  The second function returns the error, if any, that stopped the closure early, and should be checked once it returns false
+
+    next, errf, err := Iterate(service.ReadAll(&device))
+    if err != nil {
+        return err
+    }
+    for next(&device) {
+        fmt.Println(device)
+    }
+    return errf()
+*/
+func Iterate(cursor *Cursor, err error) (func(record ...interface{}) bool, func() error, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	next := func(records ...interface{}) bool {
+		if !cursor.Next() {
+			return false
+		}
+		if err := cursor.Scan(records...); err != nil {
+			cursor.Close()
+			cursor.lastErr = err
+			return false
+		}
+		return true
+	}
+	return next, cursor.Err, nil
+}
+
+/*
+This is a source with no rows, used by services that do not store anything
+*/
+type emptySource struct{}
+
+func (source emptySource) next() bool                        { return false }
+func (source emptySource) scan(records ...interface{}) error { return nil }
+func (source emptySource) err() error                        { return nil }
+func (source emptySource) close() error                      { return nil }
+
+/*
+This makes sure Scan was given a pointer to each of the record types that were read, in the same order
+*/
+func checkRecords(types []reflect.Type, records []interface{}) error {
+	if len(records) != len(types) {
+		return RecordError(fmt.Sprintf("Scan expected %v records, got %v", len(types), len(records)))
+	}
+	for i, record := range records {
+		typ := reflect.TypeOf(record)
+		if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem() != types[i] {
+			return RecordError(fmt.Sprintf("Scan expected a *%v, got %T", types[i].Name(), record))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package records
+
+import (
+	"database/sql"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"testing"
+)
+
+/*
+A cursor is read with Next and Scan, and reports why the iteration stopped with Err
+*/
+func ExampleCursor() {
+	service := NewMemoryService()
+	service.Define(&Device{})
+	service.CreateAll([]Device{{Name: "Device 1"}, {Name: "Device 2"}})
+
+	cursor, err := service.ReadAll(&Device{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer cursor.Close()
+	device := Device{}
+	for cursor.Next() {
+		err = cursor.Scan(&device)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(device)
+	}
+	fmt.Println(cursor.Err())
+
+	//Output:
+	//{1 0 Device 1}
+	//{2 0 Device 2}
+	//<nil>
+}
+
+/*
+Iterate keeps the closure style working.  The error that stops the closure early is returned by the second function
+*/
+func ExampleIterate() {
+	service := NewMemoryService()
+	service.Define(&Device{})
+	service.CreateAll([]Device{{Name: "Device 1"}, {Name: "Device 2"}})
+
+	device := Device{}
+	next, errf, err := Iterate(service.ReadAll(&device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for next(&device) {
+		fmt.Println(device)
+	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
+
+	next, errf, _ = Iterate(service.ReadAll(&device))
+	for next(&Object{}) {
+	}
+	fmt.Println(errf())
+
+	_, _, err = Iterate(service.ReadAllWhere(&device, matcher.Buggy()))
+	fmt.Println(err != nil)
+
+	//Output:
+	//{1 0 Device 1}
+	//{2 0 Device 2}
+	//Scan expected a *Device, got *records.Object
+	//true
+}
+
+func TestSqliteCursor(t *testing.T) {
+	type Foo struct {
+		Id int64 `sql:"primary"`
+		A  string
+		B  int64
+	}
+	c, _ := sql.Open("sqlite3", ":memory:")
+	//A leaked result set would hold the only connection, and block the next query
+	c.SetMaxOpenConns(1)
+	service := NewSqliteService(c)
+	_, err := c.Exec("CREATE TABLE Foo(`Id` integer primary key, `A` string, `B` integer)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Exec("INSERT INTO Foo VALUES (1, 'one', 1), (2, '22', 2), (3, 'three', NULL)")
+
+	cursor, err := service.ReadAll(&Foo{}, QueryOptions{OrderBy: []Order{Asc("Id")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cursor.Scan(&Foo{}); err == nil {
+		t.Error("Expected an error scanning before Next")
+	}
+	cursor.Next()
+	if err = cursor.Scan(&Device{}); err == nil {
+		t.Error("Expected an error scanning into the wrong type")
+	}
+	if err = cursor.Scan(&Foo{}, &Foo{}); err == nil {
+		t.Error("Expected an error scanning into too many records")
+	}
+
+	found := make([]string, 0)
+	temp := Foo{}
+	for ok := true; ok; ok = cursor.Next() {
+		err = cursor.Scan(&temp)
+		found = append(found, fmt.Sprintf("%v %v", temp, err))
+	}
+	expected := "[{1 one 1} <nil> {2 22 2} <nil> {3 three 2} Cannot convert NULL to int64 for field B]"
+	if fmt.Sprint(found) != expected {
+		t.Errorf("got:%v, want:%v", found, expected)
+	}
+	if cursor.Err() != nil || cursor.Next() || cursor.Scan(&temp) == nil {
+		t.Error("Expected the cursor to be closed cleanly")
+	}
+
+	//Stopping early and closing releases the connection
+	cursor, _ = service.ReadAll(&Foo{})
+	cursor.Next()
+	cursor.Close()
+	cursor.Close()
+	if err = service.Get(1, &temp); err != nil {
+		t.Error(err)
+	}
+
+	//Read reports the conversion error, instead of panicking
+	if err = service.Get(3, &temp); err == nil {
+		t.Error("Expected a conversion error")
+	}
+}
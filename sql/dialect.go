@@ -0,0 +1,372 @@
+package records
+
+import (
+	"database/sql"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+This describes how a flavour of sql differs from the others.  The sql record service builds every statement through a dialect, so the same service can talk to sqlite, MySQL or PostgreSQL.  Statements are built with ? placeholders, and Placeholder is used to rebind them once they are complete
+*/
+type Dialect interface {
+	matcher.Dialect
+	Name() string
+	//This is the placeholder for the nth bound value, counting from 1
+	Placeholder(n int) string
+	ColumnType(field goflect.Info) string
+	//This is the keyword that follows primary key on an autoincremented column.  It is empty when the column type implies it
+	Autoincrement() string
+	//This declares a non unique index inside CREATE TABLE, or returns an empty string if the dialect uses CreateIndex instead
+	TableIndex(name, column string) string
+	//This creates an index after the table, or returns an empty string if the dialect uses TableIndex instead
+	CreateIndex(name, table, column string, unique bool) string
+	//This declares a foreign key.  The parent column is empty when it is not known, in which case the dialect should refer to the parent's primary key, or return an empty string if it cannot
+	ForeignKey(column, parent, parentColumn, onDelete string) string
+	//This is the value bound to LIMIT when only an offset is wanted
+	NoLimit() interface{}
+	//This is the clause that follows INSERT ... VALUES to update the columns of a row whose keys already exist
+	Upsert(table string, columns, keys []string) string
+	//This is the value bound for a bool.  Databases without a boolean type store a number
+	BindBool(value bool) interface{}
+	//This is the most values that can be bound to one statement
+	MaxParameters() int
+	//This is the clause that makes an INSERT return the generated keys, or an empty string if the driver reports them through LastInsertId
+	Returning(column string) string
+	//This works out the generated keys of a multi-row INSERT from LastInsertId, for the dialects without Returning
+	InsertedIds(result sql.Result, rows int) ([]int64, error)
+}
+
+/*
+This returns the dialect for sqlite.  It is what NewSqliteService uses
+*/
+func SqliteDialect() Dialect {
+	return sqliteDialect{}
+}
+
+/*
+This returns the dialect for MySQL, using InnoDB tables.  Strings that are indexed are stored as varchar(255), since MySQL cannot index a text column without a prefix length
+*/
+func MysqlDialect() Dialect {
+	return mysqlDialect{}
+}
+
+/*
+This returns the dialect for PostgreSQL.  Unquoted names are folded to lower case by PostgreSQL, so every table and column is quoted
+*/
+func PostgresDialect() Dialect {
+	return postgresDialect{}
+}
+
+type sqliteDialect struct{}
+
+func (d sqliteDialect) Name() string                         { return "sqlite" }
+func (d sqliteDialect) QuoteIdentifier(name string) string   { return backquote(name) }
+func (d sqliteDialect) Placeholder(n int) string             { return "?" }
+func (d sqliteDialect) ColumnType(field goflect.Info) string { return sqliteType(field) }
+func (d sqliteDialect) Autoincrement() string                { return "autoincrement" }
+func (d sqliteDialect) TableIndex(name, column string) string {
+	return ""
+}
+
+// sqlite requires a limit to use an offset, and treats a negative one as no limit
+func (d sqliteDialect) NoLimit() interface{} { return int64(-1) }
+
+func (d sqliteDialect) RegexOperator(negate bool) string {
+	if negate {
+		return "NOT MATCH"
+	}
+	return "MATCH"
+}
+
+func (d sqliteDialect) LikeOperator(negate bool) string {
+	if negate {
+		return "NOT LIKE"
+	}
+	return "LIKE"
+}
+
+func (d sqliteDialect) CreateIndex(name, table, column string, unique bool) string {
+	return createIndex(d, name, table, column, unique)
+}
+
+func (d sqliteDialect) ForeignKey(column, parent, parentColumn, onDelete string) string {
+	return foreignKeyClause(d, column, parent, "", onDelete)
+}
+
+func (d sqliteDialect) Upsert(table string, columns, keys []string) string {
+	return onConflict(d, columns, keys)
+}
+
+func (d sqliteDialect) BindBool(value bool) interface{} { return boolNumber(value) }
+
+// This is SQLITE_MAX_VARIABLE_NUMBER for sqlite before 3.32
+func (d sqliteDialect) MaxParameters() int             { return 999 }
+func (d sqliteDialect) Returning(column string) string { return "" }
+
+// sqlite numbers the rows of one INSERT one after another, and reports the last
+func (d sqliteDialect) InsertedIds(result sql.Result, rows int) ([]int64, error) {
+	last, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return consecutiveIds(last-int64(rows)+1, rows), nil
+}
+
+type mysqlDialect struct{}
+
+func (d mysqlDialect) Name() string                       { return "mysql" }
+func (d mysqlDialect) QuoteIdentifier(name string) string { return backquote(name) }
+func (d mysqlDialect) Placeholder(n int) string           { return "?" }
+func (d mysqlDialect) Autoincrement() string              { return "auto_increment" }
+func (d mysqlDialect) NoLimit() interface{}               { return int64(math.MaxInt64) }
+func (d mysqlDialect) BindBool(value bool) interface{}    { return boolNumber(value) }
+
+func (d mysqlDialect) RegexOperator(negate bool) string {
+	if negate {
+		return "NOT REGEXP"
+	}
+	return "REGEXP"
+}
+
+// LIKE ignores case under the default collations
+func (d mysqlDialect) LikeOperator(negate bool) string {
+	if negate {
+		return "NOT LIKE"
+	}
+	return "LIKE"
+}
+
+func (d mysqlDialect) ColumnType(field goflect.Info) string {
+	lookup := map[reflect.Kind]string{
+		reflect.Bool:    "boolean",
+		reflect.Int:     "bigint",
+		reflect.Int8:    "tinyint",
+		reflect.Int16:   "smallint",
+		reflect.Int32:   "int",
+		reflect.Int64:   "bigint",
+		reflect.Uint:    "bigint unsigned",
+		reflect.Uint8:   "tinyint unsigned",
+		reflect.Uint16:  "smallint unsigned",
+		reflect.Uint32:  "int unsigned",
+		reflect.Uint64:  "bigint unsigned",
+		reflect.Float32: "float",
+		reflect.Float64: "double",
+	}
+	if kind, present := lookup[field.Kind]; present {
+		return kind
+	}
+	if field.IsIndexed {
+		return "varchar(255)"
+	}
+	return "text"
+}
+
+func (d mysqlDialect) TableIndex(name, column string) string {
+	return "INDEX " + d.QuoteIdentifier(name) + " (" + d.QuoteIdentifier(column) + ")"
+}
+
+// MySQL has no IF NOT EXISTS for indexes, so they are declared with the table instead
+func (d mysqlDialect) CreateIndex(name, table, column string, unique bool) string {
+	return ""
+}
+
+// MySQL cannot refer to a parent without naming its column
+func (d mysqlDialect) ForeignKey(column, parent, parentColumn, onDelete string) string {
+	if parentColumn == "" {
+		return ""
+	}
+	return foreignKeyClause(d, column, parent, parentColumn, onDelete)
+}
+
+func (d mysqlDialect) Upsert(table string, columns, keys []string) string {
+	updates := make([]string, 0)
+	for _, column := range columns {
+		updates = append(updates, d.QuoteIdentifier(column)+" = VALUES("+d.QuoteIdentifier(column)+")")
+	}
+	if len(updates) == 0 {
+		//MySQL has no DO NOTHING, so a key is assigned to itself
+		updates = append(updates, d.QuoteIdentifier(keys[0])+" = "+d.QuoteIdentifier(keys[0]))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (d mysqlDialect) MaxParameters() int             { return 65535 }
+func (d mysqlDialect) Returning(column string) string { return "" }
+
+// MySQL reports the first id of a multi-row INSERT.  The rest follow it, unless innodb_autoinc_lock_mode is 2 and other sessions insert at the same time
+func (d mysqlDialect) InsertedIds(result sql.Result, rows int) ([]int64, error) {
+	first, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return consecutiveIds(first, rows), nil
+}
+
+type postgresDialect struct{}
+
+func (d postgresDialect) Name() string                          { return "postgres" }
+func (d postgresDialect) QuoteIdentifier(name string) string    { return quoteWith("\"", name) }
+func (d postgresDialect) Placeholder(n int) string              { return "$" + strconv.Itoa(n) }
+func (d postgresDialect) Autoincrement() string                 { return "" }
+func (d postgresDialect) TableIndex(name, column string) string { return "" }
+func (d postgresDialect) NoLimit() interface{}                  { return nil }
+
+// PostgreSQL will not cast a number to boolean when it is bound
+func (d postgresDialect) BindBool(value bool) interface{} { return value }
+
+func (d postgresDialect) RegexOperator(negate bool) string {
+	if negate {
+		return "!~"
+	}
+	return "~"
+}
+
+// LIKE is case sensitive in PostgreSQL, and ILIKE ignores case as sqlite does
+func (d postgresDialect) LikeOperator(negate bool) string {
+	if negate {
+		return "NOT ILIKE"
+	}
+	return "ILIKE"
+}
+
+func (d postgresDialect) ColumnType(field goflect.Info) string {
+	if field.IsAutoincrement {
+		return "bigserial"
+	}
+	lookup := map[reflect.Kind]string{
+		reflect.Bool:    "boolean",
+		reflect.Int:     "bigint",
+		reflect.Int8:    "smallint",
+		reflect.Int16:   "smallint",
+		reflect.Int32:   "integer",
+		reflect.Int64:   "bigint",
+		reflect.Uint:    "bigint",
+		reflect.Uint8:   "smallint",
+		reflect.Uint16:  "integer",
+		reflect.Uint32:  "bigint",
+		reflect.Uint64:  "bigint",
+		reflect.Float32: "real",
+		reflect.Float64: "double precision",
+	}
+	if kind, present := lookup[field.Kind]; present {
+		return kind
+	}
+	return "text"
+}
+
+func (d postgresDialect) CreateIndex(name, table, column string, unique bool) string {
+	return createIndex(d, name, table, column, unique)
+}
+
+func (d postgresDialect) ForeignKey(column, parent, parentColumn, onDelete string) string {
+	return foreignKeyClause(d, column, parent, "", onDelete)
+}
+
+func (d postgresDialect) Upsert(table string, columns, keys []string) string {
+	return onConflict(d, columns, keys)
+}
+
+func (d postgresDialect) MaxParameters() int { return 65535 }
+
+func (d postgresDialect) Returning(column string) string {
+	return "RETURNING " + d.QuoteIdentifier(column)
+}
+
+// PostgreSQL drivers do not support LastInsertId, so Returning is used instead
+func (d postgresDialect) InsertedIds(result sql.Result, rows int) ([]int64, error) {
+	return nil, RecordError("LastInsertId is not supported by PostgreSQL")
+}
+
+func consecutiveIds(first int64, rows int) []int64 {
+	output := make([]int64, rows)
+	for i := range output {
+		output[i] = first + int64(i)
+	}
+	return output
+}
+
+func backquote(name string) string {
+	return quoteWith("`", name)
+}
+
+/*
+This quotes an identifier, doubling any quote inside it
+*/
+func quoteWith(quote, name string) string {
+	return quote + strings.Replace(name, quote, quote+quote, -1) + quote
+}
+
+func createIndex(d Dialect, name, table, column string, unique bool) string {
+	statement := "CREATE INDEX"
+	if unique {
+		statement = "CREATE UNIQUE INDEX"
+	}
+	return statement + " IF NOT EXISTS " + d.QuoteIdentifier(name) + " ON " + d.QuoteIdentifier(table) + "(" + d.QuoteIdentifier(column) + ")"
+}
+
+func foreignKeyClause(d Dialect, column, parent, parentColumn, onDelete string) string {
+	clause := "FOREIGN KEY (" + d.QuoteIdentifier(column) + ") REFERENCES " + d.QuoteIdentifier(parent)
+	if parentColumn != "" {
+		clause += "(" + d.QuoteIdentifier(parentColumn) + ")"
+	}
+	return clause + " ON DELETE " + strings.ToUpper(onDelete)
+}
+
+/*
+This is the upsert clause shared by sqlite and PostgreSQL
+*/
+func onConflict(d Dialect, columns, keys []string) string {
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quoted = append(quoted, d.QuoteIdentifier(key))
+	}
+	clause := "ON CONFLICT (" + strings.Join(quoted, ", ") + ") DO "
+	if len(columns) == 0 {
+		return clause + "NOTHING"
+	}
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		updates = append(updates, d.QuoteIdentifier(column)+" = excluded."+d.QuoteIdentifier(column))
+	}
+	return clause + "UPDATE SET " + strings.Join(updates, ", ")
+}
+
+/*
+This replaces each ? in the statement with the dialect's placeholder.  Anything inside quotes is left alone
+*/
+func rebind(d Dialect, statement string) string {
+	if d.Placeholder(1) == "?" {
+		return statement
+	}
+	output := strings.Builder{}
+	var quote rune
+	n := 0
+	for _, c := range statement {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			output.WriteString(d.Placeholder(n))
+			continue
+		}
+		output.WriteRune(c)
+	}
+	return output.String()
+}
+
+func boolNumber(value bool) interface{} {
+	if value {
+		return int64(1)
+	}
+	return int64(0)
+}
@@ -0,0 +1,247 @@
+package records
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"flag"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+/*
+This is a database/sql driver that writes down every statement it is given, and returns no rows.  It lets the generated sql for each dialect be compared to a golden file, without a database server
+*/
+type recordingDriver struct {
+	lock sync.Mutex
+	logs map[string]*strings.Builder
+}
+
+var recorder = &recordingDriver{logs: make(map[string]*strings.Builder)}
+
+func init() {
+	sql.Register("goflect-recorder", recorder)
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, present := d.logs[name]; !present {
+		d.logs[name] = &strings.Builder{}
+	}
+	return &recordingConn{driver: d, log: d.logs[name]}, nil
+}
+
+/*
+This starts a new log for the named database
+*/
+func (d *recordingDriver) reset(name string) *strings.Builder {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.logs[name] = &strings.Builder{}
+	return d.logs[name]
+}
+
+func (d *recordingDriver) write(log *strings.Builder, statement string, args []driver.NamedValue) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	log.WriteString(statement + ";\n")
+	if len(args) > 0 {
+		values := make([]string, 0, len(args))
+		for _, arg := range args {
+			values = append(values, fmt.Sprintf("%#v", arg.Value))
+		}
+		log.WriteString("-- args: " + strings.Join(values, ", ") + "\n")
+	}
+}
+
+func (d *recordingDriver) comment(log *strings.Builder, text string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if log.Len() > 0 {
+		log.WriteString("\n")
+	}
+	log.WriteString("-- " + text + "\n")
+}
+
+type recordingConn struct {
+	driver *recordingDriver
+	log    *strings.Builder
+}
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("Prepare is not supported by the recorder")
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.driver.write(c.log, "BEGIN", nil)
+	return recordingTx{c}, nil
+}
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.write(c.log, query, args)
+	return recordingResult{}, nil
+}
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.driver.write(c.log, query, args)
+	return recordingRows{}, nil
+}
+
+type recordingResult struct{}
+
+func (result recordingResult) LastInsertId() (int64, error) { return 0, nil }
+func (result recordingResult) RowsAffected() (int64, error) { return 0, nil }
+
+type recordingTx struct{ conn *recordingConn }
+
+func (tx recordingTx) Commit() error {
+	tx.conn.driver.write(tx.conn.log, "COMMIT", nil)
+	return nil
+}
+func (tx recordingTx) Rollback() error {
+	tx.conn.driver.write(tx.conn.log, "ROLLBACK", nil)
+	return nil
+}
+
+type recordingRows struct{}
+
+func (rows recordingRows) Columns() []string              { return []string{} }
+func (rows recordingRows) Close() error                   { return nil }
+func (rows recordingRows) Next(dest []driver.Value) error { return io.EOF }
+
+type (
+	goldenPeer struct {
+		Id   int64  `sql:"primary,autoincrement"`
+		Name string `sql:"unique"`
+	}
+
+	goldenDevice struct {
+		Id     int64  `sql:"primary,autoincrement"`
+		PeerId int64  `sql-child:"goldenPeer"`
+		Name   string `sql:"not-null,index"`
+		Active bool
+		Weight float64
+		Flags  uint8
+		Notes  string
+	}
+
+	goldenLocation struct {
+		DeviceId int64  `sql:"primary" sql-extend:"goldenDevice"`
+		Location string `sql-column:"location_name"`
+	}
+)
+
+func TestDialectGolden(t *testing.T) {
+	for _, dialect := range []Dialect{SqliteDialect(), MysqlDialect(), PostgresDialect()} {
+		t.Run(dialect.Name(), func(t *testing.T) {
+			log := recorder.reset(dialect.Name())
+			c, err := sql.Open("goflect-recorder", dialect.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.SetMaxOpenConns(1)
+			service := NewSqlService(c, dialect)
+			run := func(name string, op func() error) {
+				recorder.comment(log, name)
+				if err := op(); err != nil {
+					t.Errorf("%v: %v", name, err)
+				}
+			}
+			read := func(match matcher.Matcher, options QueryOptions, records ...interface{}) func() error {
+				return func() error {
+					cursor, err := service.delegate.readAll(context.Background(), match, options, records...)
+					if err == nil {
+						cursor.Close()
+					}
+					return err
+				}
+			}
+			match := matcher.NewStructMatcher()
+			match.AddField("Name", matcher.Match("^Device"))
+			match.AddField("Id", matcher.In([]int64{1, 2}))
+
+			run("Define", func() error { return service.DefineAll(&goldenLocation{}, &goldenDevice{}, &goldenPeer{}) })
+			run("Create", func() error { return service.Create(&goldenPeer{Name: "Peer 1"}) })
+			run("CreateAll", func() error {
+				return service.CreateAll([]goldenDevice{{PeerId: 1, Name: "Device 1", Active: true}, {PeerId: 1, Name: "Device 2", Weight: 1.5}})
+			})
+			run("Update", func() error { return service.Update(&goldenDevice{Id: 1, PeerId: 1, Name: "Renamed", Flags: 3}) })
+			run("UpdateFields", func() error { return service.UpdateFields(&goldenDevice{Id: 1, Weight: 2.5}, "Weight") })
+			run("UpdateAllWhere", func() error {
+				where := matcher.NewStructMatcher()
+				where.AddField("Location", matcher.NotMatch("x"))
+				return service.UpdateAllWhere(&goldenLocation{Location: "Nowhere"}, matcher.Or(where, matcher.None()))
+			})
+			run("DeleteById", func() error { return service.DeleteById(1, &goldenDevice{}) })
+			run("ReadAllWhere with options", read(match, QueryOptions{OrderBy: []Order{Desc("Name")}, Limit: 10, Offset: 20}, &goldenDevice{}))
+			run("ReadAll with only an offset", read(matcher.Any(), QueryOptions{Offset: 20}, &goldenDevice{}))
+			run("ReadAll after", read(matcher.Any(), QueryOptions{After: int64(5)}, &goldenDevice{}))
+			run("Join", read(matcher.Any(), QueryOptions{}, &goldenDevice{}, &goldenPeer{}, &goldenLocation{}))
+			patterns := matcher.NewStructMatcher()
+			patterns.AddField("Name", matcher.And(matcher.Like("Device _"), matcher.StartsWith("Dev_"), matcher.NotContains("100%"), matcher.Ieq("DEVICE 1")))
+			patterns.AddField("Id", matcher.Between(int64(1), int64(2)))
+			run("ReadAllWhere patterns", read(patterns, QueryOptions{}, &goldenDevice{}))
+			run("Transaction", func() error {
+				return service.Transaction(func(tx RecordService) error {
+					tx.DeleteAll(&goldenLocation{})
+					tx.Transaction(func(tx RecordService) error {
+						tx.DeleteAll(&goldenDevice{})
+						return RecordError("Rolled back")
+					})
+					return nil
+				})
+			})
+			run("Batched CreateAll", func() error {
+				return service.WithBatchSize(1).CreateAll([]goldenPeer{{Name: "Peer 2"}, {Name: "Peer 3"}})
+			})
+			run("UpsertAll", func() error {
+				return service.UpsertAll([]goldenDevice{{Id: 2, PeerId: 1, Name: "Device 2", Notes: "Updated"}, {PeerId: 1, Name: "Device 3"}})
+			})
+			run("UpsertAllBy", func() error { return service.UpsertAllBy("Name", []goldenPeer{{Name: "Peer 1"}, {Name: "Peer 4"}}) })
+			run("Upsert", func() error { return service.Upsert(&goldenLocation{DeviceId: 2, Location: "Upstairs"}) })
+			c.Close()
+
+			//Savepoint names come from a counter shared by every test
+			found := regexp.MustCompile("goflect_[0-9]+").ReplaceAllString(log.String(), "goflect_N")
+			golden := filepath.Join("testdata", dialect.Name()+".golden")
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, []byte(found), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != string(expected) {
+				t.Errorf("The generated sql does not match %v, run the tests with -update to see the difference\n%v", golden, found)
+			}
+		})
+	}
+}
+
+func TestMysqlForeignKeyParent(t *testing.T) {
+	recorder.reset("mysql parents")
+	c, err := sql.Open("goflect-recorder", "mysql parents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewSqlService(c, MysqlDialect())
+	if err := service.Define(&goldenDevice{}); err == nil {
+		t.Error("Expected an error defining a child before MySQL knows the primary key of its parent")
+	}
+	if err := service.DefineAll(&goldenDevice{}, &goldenPeer{}); err != nil {
+		t.Errorf("Expected the parent to be defined first, got %v", err)
+	}
+	if err := NewSqlService(c, SqliteDialect()).Define(&goldenDevice{}); err != nil {
+		t.Errorf("sqlite refers to the primary key of the parent without naming it, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package records
 
 import (
+	"context"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
 )
 
@@ -9,31 +10,76 @@ This is a dummy service intended for use with testing.  It can be consumed both
 */
 type dummyService struct {
 	Creates int
+	Upserts int
 	Updates int
 	Reads   int
 	Deletes int
+	Options QueryOptions
 }
 
-func (service *dummyService) createAll(record interface{}) error {
+func (service *dummyService) createAll(ctx context.Context, record interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	service.Creates++
 	return nil
 }
 
-func (service *dummyService) readAll(query matcher.Matcher, record ...interface{}) (func(record ...interface{}) bool, error) {
+func (service *dummyService) upsertAll(ctx context.Context, record interface{}, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	service.Upserts++
+	return nil
+}
+
+func (service *dummyService) readAll(ctx context.Context, query matcher.Matcher, options QueryOptions, record ...interface{}) (*Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	_, err := query.Match(record)
 	if err != nil {
 		return nil, err
 	}
+	_, err = options.validate(record[0])
+	if err != nil {
+		return nil, err
+	}
+	service.Options = options
 	service.Reads++
-	return func(record ...interface{}) bool { return false }, nil
+	return newCursor(emptySource{}), nil
 }
 
-func (service *dummyService) updateAll(record interface{}, match matcher.Matcher) error {
+func (service *dummyService) updateAll(ctx context.Context, record interface{}, fields []string, match matcher.Matcher) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	service.Updates++
 	return nil
 }
 
-func (service *dummyService) deleteAll(record interface{}, match matcher.Matcher) error {
+func (service *dummyService) deleteAll(ctx context.Context, record interface{}, match matcher.Matcher) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	service.Deletes++
 	return nil
 }
+
+/*
+The dummy service has nothing to roll back, so a transaction is the service itself
+*/
+func (service *dummyService) begin(ctx context.Context) (transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (service *dummyService) commit() error {
+	return nil
+}
+
+func (service *dummyService) rollback() error {
+	return nil
+}
@@ -0,0 +1,779 @@
+package records
+
+import (
+	"context"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+This is an in memory record service.  It stores copies of the records per type, and evaluates matchers directly with Match.  It follows the same rules as the sqlite service for primary, unique, autoincrement and immutable fields, and enforces foreign keys the way sqlite does with foreign_keys on, so it can stand in for a database in unit tests
+*/
+type memoryService struct {
+	lock   sync.Mutex
+	tables map[string]*memoryTable
+}
+
+type memoryTable struct {
+	fields []goflect.Info
+	rows   []reflect.Value
+	nextId int64
+}
+
+/*
+This is the memory service's emulation of a transaction.  Operations are applied to a copy of the tables, which replaces the parent's tables on commit.  It is isolated from other callers until then, but writes made by other callers in the meantime are lost on commit
+*/
+type memoryTransaction struct {
+	*memoryService
+	parent *memoryService
+}
+
+func (service *memoryService) begin(ctx context.Context) (transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	clone := &memoryService{tables: make(map[string]*memoryTable)}
+	for name, table := range service.tables {
+		local := *table
+		local.rows = append(make([]reflect.Value, 0, len(table.rows)), table.rows...)
+		clone.tables[name] = &local
+	}
+	return memoryTransaction{memoryService: clone, parent: service}, nil
+}
+
+func (tx memoryTransaction) commit() error {
+	tx.parent.lock.Lock()
+	defer tx.parent.lock.Unlock()
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	tx.parent.tables = tx.tables
+	return nil
+}
+
+func (tx memoryTransaction) rollback() error {
+	return nil
+}
+
+/*
+This creates the table for the record type, if it does not exist already
+*/
+func (service *memoryService) Define(record interface{}) error {
+	if err := checkOnDelete(record); err != nil {
+		return err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	typ, _ := typeAndVal(record)
+	if service.tables == nil {
+		service.tables = make(map[string]*memoryTable)
+	}
+	if _, present := service.tables[typ.Name()]; !present {
+		service.tables[typ.Name()] = &memoryTable{fields: goflect.GetInfo(record), nextId: 1}
+	}
+	return nil
+}
+
+func (service *memoryService) table(record interface{}) (*memoryTable, error) {
+	typ, _ := typeAndVal(record)
+	table, present := service.tables[typ.Name()]
+	if !present {
+		return nil, RecordError("no such table: " + typ.Name())
+	}
+	return table, nil
+}
+
+/*
+This returns a copy of the record that is safe to store or hand back.  The values held by pointer fields are copied too, so the caller and the table never share them
+*/
+func copyRecord(val reflect.Value) reflect.Value {
+	if val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	output := reflect.New(val.Type()).Elem()
+	output.Set(val)
+	for i := 0; i < output.NumField(); i++ {
+		if output.Field(i).CanSet() {
+			output.Field(i).Set(copyField(output.Field(i)))
+		}
+	}
+	return output
+}
+
+/*
+This copies the value held by a pointer field.  Other fields are values already, and are returned as they are
+*/
+func copyField(val reflect.Value) reflect.Value {
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return val
+	}
+	output := reflect.New(val.Type().Elem())
+	output.Elem().Set(val.Elem())
+	return output
+}
+
+/*
+This checks that every unique field has no repeated values across the provided rows.  Optional fields are compared by the values they hold, and NULL may repeat, as it does in sql
+*/
+func (table *memoryTable) checkUnique(name string, rows []reflect.Value) error {
+	for _, field := range table.fields {
+		if !field.IsUnique {
+			continue
+		}
+		seen := make(map[interface{}]bool)
+		for _, row := range rows {
+			value := row.FieldByName(field.Name)
+			if field.IsOptional {
+				held, valid := nullValue(value)
+				if !valid {
+					continue
+				}
+				value = held
+			}
+			if seen[value.Interface()] {
+				return RecordError("UNIQUE constraint failed: " + name + "." + field.Name)
+			}
+			seen[value.Interface()] = true
+		}
+	}
+	return nil
+}
+
+/*
+This returns the primary key field of the table, if there is one
+*/
+func (table *memoryTable) primary() (goflect.Info, bool) {
+	for _, field := range table.fields {
+		if field.IsPrimary {
+			return field, true
+		}
+	}
+	return goflect.Info{}, false
+}
+
+/*
+This returns the rows of the named table, as they would be after the pending changes
+*/
+func (service *memoryService) pendingRows(changes map[string][]reflect.Value, name string) []reflect.Value {
+	if rows, present := changes[name]; present {
+		return rows
+	}
+	return service.tables[name].rows
+}
+
+/*
+This returns the names of the tables in a fixed order, so constraint errors are reported consistently
+*/
+func (service *memoryService) tableNames() []string {
+	names := make([]string, 0, len(service.tables))
+	for name := range service.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+This returns the primary keys of the rows, converted so they can be compared with the fields that point to them
+*/
+func primaryKeys(primary goflect.Info, rows []reflect.Value) map[interface{}]bool {
+	keys := make(map[interface{}]bool)
+	for _, row := range rows {
+		keys[row.FieldByName(primary.Name).Interface()] = true
+	}
+	return keys
+}
+
+/*
+This converts a field to the type of the primary key it points to, so that an int64 child field matches an int primary key.  Numbers are never converted to strings, or the other way around.  A pointer or a sql.Null field is compared by the value it holds, and is false when it is NULL, since NULL points to nothing
+*/
+func foreignKey(val reflect.Value, field goflect.Info, primary reflect.Type) (interface{}, bool) {
+	if field.IsOptional {
+		held, valid := nullValue(val)
+		if !valid {
+			return nil, false
+		}
+		val = held
+	}
+	if val.Type() != primary && val.Type().ConvertibleTo(primary) && (val.Kind() == reflect.String) == (primary.Kind() == reflect.String) {
+		return val.Convert(primary).Interface(), true
+	}
+	return val.Interface(), true
+}
+
+/*
+This checks every child and extension field in, or pointing to, the changed tables.  Each one must match the primary key of a row in its parent table.  Relationships to a type that has not been defined, or that has no primary key, are not checked
+*/
+func (service *memoryService) checkForeignKeys(changes map[string][]reflect.Value) error {
+	for _, name := range service.tableNames() {
+		for _, field := range service.tables[name].fields {
+			parentName := parentOf(field)
+			parent, present := service.tables[parentName]
+			_, changed := changes[name]
+			_, parentChanged := changes[parentName]
+			if field.IsSqlIgnored || !present || !(changed || parentChanged) {
+				continue
+			}
+			primary, found := parent.primary()
+			if !found {
+				continue
+			}
+			parentRows := service.pendingRows(changes, parentName)
+			keys := primaryKeys(primary, parentRows)
+			for _, row := range service.pendingRows(changes, name) {
+				//A NULL child points to nothing, so it is not checked
+				if field.IsOptional {
+					if _, valid := nullValue(row.FieldByName(field.Name)); !valid {
+						continue
+					}
+				}
+				if len(parentRows) == 0 {
+					return RecordError("FOREIGN KEY constraint failed: " + name + "." + field.Name)
+				}
+				if key, _ := foreignKey(row.FieldByName(field.Name), field, parentRows[0].FieldByName(primary.Name).Type()); !keys[key] {
+					return RecordError("FOREIGN KEY constraint failed: " + name + "." + field.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+This removes the rows that point to the deleted rows with on delete cascade, and the rows that point to those in turn.  The result is recorded in changes
+*/
+func (service *memoryService) cascade(changes map[string][]reflect.Value, name string, deleted []reflect.Value) {
+	primary, found := service.tables[name].primary()
+	if !found || len(deleted) == 0 {
+		return
+	}
+	keys := primaryKeys(primary, deleted)
+	primaryType := deleted[0].FieldByName(primary.Name).Type()
+	for _, childName := range service.tableNames() {
+		for _, field := range service.tables[childName].fields {
+			if field.IsSqlIgnored || parentOf(field) != name || field.OnDelete != goflect.SQL_CASCADE {
+				continue
+			}
+			kept, removed := make([]reflect.Value, 0), make([]reflect.Value, 0)
+			for _, row := range service.pendingRows(changes, childName) {
+				if key, valid := foreignKey(row.FieldByName(field.Name), field, primaryType); valid && keys[key] {
+					removed = append(removed, row)
+				} else {
+					kept = append(kept, row)
+				}
+			}
+			if len(removed) > 0 {
+				changes[childName] = kept
+				service.cascade(changes, childName, removed)
+			}
+		}
+	}
+}
+
+func (service *memoryService) createAll(ctx context.Context, record interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	_, val := typeAndVal(record)
+	if val.Len() == 0 {
+		return nil
+	}
+	first := copyRecord(val.Index(0))
+	table, err := service.table(first.Interface())
+	if err != nil {
+		return err
+	}
+
+	nextId := table.nextId
+	added := make([]reflect.Value, 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		row := copyRecord(val.Index(i))
+		for _, field := range table.fields {
+			if field.IsAutoincrement {
+				row.FieldByName(field.Name).Set(reflect.ValueOf(nextId).Convert(row.FieldByName(field.Name).Type()))
+				nextId++
+			}
+		}
+		added = append(added, row)
+	}
+
+	rows := append(append(make([]reflect.Value, 0), table.rows...), added...)
+	err = service.store(table, first.Type().Name(), rows)
+	if err != nil {
+		return err
+	}
+	table.nextId = nextId
+	if generated, found := autoincrementField(table.fields); found {
+		for i, row := range added {
+			setKey(rowAt(val, i), generated, integerOf(row.FieldByName(generated.Name)))
+		}
+	}
+	return nil
+}
+
+/*
+This replaces the rows of the table, once they pass the unique and foreign key constraints
+*/
+func (service *memoryService) store(table *memoryTable, name string, rows []reflect.Value) error {
+	err := table.checkUnique(name, rows)
+	if err != nil {
+		return err
+	}
+	err = service.checkForeignKeys(map[string][]reflect.Value{name: rows})
+	if err != nil {
+		return err
+	}
+	table.rows = rows
+	return nil
+}
+
+/*
+This works through the records in order, updating the stored row with the same key, or adding the record when there is none.  An explicit value in an autoincremented key is kept, and later keys are generated after it
+*/
+func (service *memoryService) upsertAll(ctx context.Context, record interface{}, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	_, val := typeAndVal(record)
+	if val.Len() == 0 {
+		return nil
+	}
+	first := copyRecord(val.Index(0))
+	table, err := service.table(first.Interface())
+	if err != nil {
+		return err
+	}
+
+	nextId := table.nextId
+	rows := append(make([]reflect.Value, 0), table.rows...)
+	for i := 0; i < val.Len(); i++ {
+		row := copyRecord(val.Index(i))
+		value := row.FieldByName(key).Interface()
+		found := -1
+		for j, stored := range rows {
+			if stored.FieldByName(key).Interface() == value {
+				found = j
+				break
+			}
+		}
+		if found >= 0 {
+			updated := copyRecord(rows[found])
+			for _, field := range table.fields {
+				if field.IsPrimary || field.IsImmutable || field.Name == key {
+					continue
+				}
+				updated.FieldByName(field.Name).Set(row.FieldByName(field.Name))
+			}
+			rows[found] = updated
+			continue
+		}
+		for _, field := range table.fields {
+			if !field.IsAutoincrement {
+				continue
+			}
+			target := row.FieldByName(field.Name)
+			if field.Name == key {
+				if id := integerOf(target); id >= nextId {
+					nextId = id + 1
+				}
+				continue
+			}
+			target.Set(reflect.ValueOf(nextId).Convert(target.Type()))
+			nextId++
+		}
+		rows = append(rows, row)
+	}
+
+	err = service.store(table, first.Type().Name(), rows)
+	if err != nil {
+		return err
+	}
+	table.nextId = nextId
+	return nil
+}
+
+/*
+This splits the table into the rows that match, and the rows that do not.  Any error from the matcher is returned as is
+*/
+func (table *memoryTable) partition(match matcher.Matcher) (hits, misses []int, err error) {
+	for i, row := range table.rows {
+		result, err := match.Match(row.Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+		if result {
+			hits = append(hits, i)
+		} else {
+			misses = append(misses, i)
+		}
+	}
+	return hits, misses, nil
+}
+
+func (service *memoryService) updateAll(ctx context.Context, record interface{}, fields []string, match matcher.Matcher) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	_, val := typeAndVal(record)
+	table, err := service.table(record)
+	if err != nil {
+		return err
+	}
+	hits, _, err := table.partition(match)
+	if err != nil {
+		return err
+	}
+
+	rows := append(make([]reflect.Value, 0), table.rows...)
+	for _, i := range hits {
+		row := copyRecord(rows[i])
+		for _, name := range fields {
+			row.FieldByName(name).Set(copyField(val.FieldByName(name)))
+		}
+		rows[i] = row
+	}
+	return service.store(table, val.Type().Name(), rows)
+}
+
+func (service *memoryService) deleteAll(ctx context.Context, record interface{}, match matcher.Matcher) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	table, err := service.table(record)
+	if err != nil {
+		return err
+	}
+	hits, misses, err := table.partition(match)
+	if err != nil {
+		return err
+	}
+	rows := make([]reflect.Value, 0, len(misses))
+	for _, i := range misses {
+		rows = append(rows, table.rows[i])
+	}
+	deleted := make([]reflect.Value, 0, len(hits))
+	for _, i := range hits {
+		deleted = append(deleted, table.rows[i])
+	}
+
+	typ, _ := typeAndVal(record)
+	changes := map[string][]reflect.Value{typ.Name(): rows}
+	service.cascade(changes, typ.Name(), deleted)
+	err = service.checkForeignKeys(changes)
+	if err != nil {
+		return err
+	}
+	for name, rows := range changes {
+		service.tables[name].rows = rows
+	}
+	return nil
+}
+
+/*
+This is an Edge, resolved to positions in the list of records being joined
+*/
+type memoryEdge struct {
+	a, b           int
+	aField, bField string
+}
+
+/*
+This builds every combination of rows that satisfy the joins between the record types, in the same way the sqlite service joins its tables
+*/
+func (service *memoryService) join(records []interface{}) ([][]reflect.Value, error) {
+	names := make(map[string]int)
+	tables := make([]*memoryTable, 0)
+	for i, record := range records {
+		table, err := service.table(record)
+		if err != nil {
+			return nil, err
+		}
+		typ, _ := typeAndVal(record)
+		names[typ.Name()] = i
+		tables = append(tables, table)
+	}
+
+	split := func(column string) (int, string) {
+		for i := len(column) - 1; i >= 0; i-- {
+			if column[i] == '.' {
+				return names[column[:i]], column[i+1:]
+			}
+		}
+		return 0, column
+	}
+	edges := make([]memoryEdge, 0)
+	if len(records) > 1 {
+		for _, edge := range determineEdges(records) {
+			local := memoryEdge{}
+			local.a, local.aField = split(edge.A)
+			local.b, local.bField = split(edge.B)
+			edges = append(edges, local)
+		}
+	}
+
+	tuples := [][]reflect.Value{{}}
+	for _, table := range tables {
+		next := make([][]reflect.Value, 0)
+		for _, tuple := range tuples {
+			for _, row := range table.rows {
+				next = append(next, append(append(make([]reflect.Value, 0, len(tables)), tuple...), row))
+			}
+		}
+		tuples = next
+	}
+
+	output := make([][]reflect.Value, 0)
+	for _, tuple := range tuples {
+		joined := true
+		for _, edge := range edges {
+			a := tuple[edge.a].FieldByName(edge.aField).Interface()
+			b := tuple[edge.b].FieldByName(edge.bField).Interface()
+			joined = joined && a == b
+		}
+		if joined {
+			output = append(output, tuple)
+		}
+	}
+	return output, nil
+}
+
+/*
+This is the record the matcher sees for a join.  Every field is available by name, with the first record winning any collision, and by a path such as Location.Id
+*/
+func joinedRecord(tuple []reflect.Value) interface{} {
+	if len(tuple) == 1 {
+		return tuple[0].Interface()
+	}
+	output := make(map[string]interface{})
+	for _, row := range tuple {
+		name := row.Type().Name()
+		for _, field := range goflect.GetInfo(row.Interface()) {
+			value := row.FieldByName(field.Name).Interface()
+			output[name+"."+field.Name] = value
+			if _, present := output[field.Name]; !present {
+				output[field.Name] = value
+			}
+		}
+	}
+	return output
+}
+
+/*
+This orders two values of the same kind, returning a negative number, zero or a positive number.  NULL orders before any value, as it does in sqlite
+*/
+func compareValues(a, b reflect.Value) int {
+	if _, nullable := matcher.NullableType(a.Type()); nullable {
+		heldA, validA := nullValue(a)
+		heldB, validB := nullValue(b)
+		switch {
+		case !validA && !validB:
+			return 0
+		case !validA:
+			return -1
+		case !validB:
+			return 1
+		}
+		return compareValues(heldA, heldB)
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case a.Int() < b.Int():
+			return -1
+		case a.Int() > b.Int():
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		}
+	case reflect.String:
+		switch {
+		case a.String() < b.String():
+			return -1
+		case a.String() > b.String():
+			return 1
+		}
+	case reflect.Bool:
+		switch {
+		case !a.Bool() && b.Bool():
+			return -1
+		case a.Bool() && !b.Bool():
+			return 1
+		}
+	case reflect.Struct:
+		if at, ok := a.Interface().(time.Time); ok {
+			bt := b.Interface().(time.Time)
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+/*
+This applies the keyset cursor, ordering, offset and limit to the matched rows
+*/
+func applyOptions(tuples [][]reflect.Value, options QueryOptions, primary goflect.Info) ([][]reflect.Value, error) {
+	if options.After != nil {
+		after := reflect.ValueOf(options.After)
+		descending := options.afterDescending()
+		filtered := make([][]reflect.Value, 0)
+		for _, tuple := range tuples {
+			key := tuple[0].FieldByName(primary.Name)
+			if !after.Type().ConvertibleTo(key.Type()) {
+				return nil, RecordError("After is not compatible with the primary key")
+			}
+			comparison := compareValues(key, after.Convert(key.Type()))
+			if (comparison > 0 && !descending) || (comparison < 0 && descending) {
+				filtered = append(filtered, tuple)
+			}
+		}
+		tuples = filtered
+	}
+
+	orders := append(make([]Order, 0), options.OrderBy...)
+	if options.After != nil && !orderContains(orders, primary.Name) {
+		orders = append(orders, Asc(primary.Name))
+	}
+	sort.SliceStable(tuples, func(i, j int) bool {
+		for _, order := range orders {
+			comparison := compareValues(tuples[i][0].FieldByName(order.Field), tuples[j][0].FieldByName(order.Field))
+			if comparison != 0 {
+				return (comparison < 0) != order.Descending
+			}
+		}
+		return false
+	})
+
+	if options.Offset > 0 {
+		if options.Offset >= int64(len(tuples)) {
+			return nil, nil
+		}
+		tuples = tuples[options.Offset:]
+	}
+	if options.Limit > 0 && options.Limit < int64(len(tuples)) {
+		tuples = tuples[:options.Limit]
+	}
+	return tuples, nil
+}
+
+func orderContains(orders []Order, name string) bool {
+	for _, order := range orders {
+		if order.Field == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (service *memoryService) readAll(ctx context.Context, query matcher.Matcher, options QueryOptions, records ...interface{}) (*Cursor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	primary, err := options.validate(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	service.lock.Lock()
+	tuples, err := service.join(records)
+	service.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([][]reflect.Value, 0)
+	for _, tuple := range tuples {
+		result, err := query.Match(joinedRecord(tuple))
+		if err != nil {
+			return nil, err
+		}
+		if result {
+			matched = append(matched, tuple)
+		}
+	}
+	matched, err = applyOptions(matched, options, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]reflect.Type, 0, len(records))
+	for _, record := range records {
+		typ, _ := typeAndVal(record)
+		types = append(types, typ)
+	}
+	return newCursor(&memoryRows{ctx: ctx, types: types, matched: matched}), nil
+}
+
+/*
+This walks a snapshot of the matched rows, so changes made while iterating are not seen
+*/
+type memoryRows struct {
+	ctx       context.Context
+	types     []reflect.Type
+	matched   [][]reflect.Value
+	position  int
+	cancelled bool
+}
+
+func (source *memoryRows) next() bool {
+	if source.ctx.Err() != nil {
+		source.cancelled = true
+		return false
+	}
+	if source.position >= len(source.matched) {
+		return false
+	}
+	source.position++
+	return true
+}
+
+func (source *memoryRows) scan(records ...interface{}) error {
+	err := checkRecords(source.types, records)
+	if err != nil {
+		return err
+	}
+	for i, record := range records {
+		reflect.ValueOf(record).Elem().Set(copyRecord(source.matched[source.position-1][i]))
+	}
+	return nil
+}
+
+func (source *memoryRows) err() error {
+	if source.cancelled {
+		return source.ctx.Err()
+	}
+	return nil
+}
+
+func (source *memoryRows) close() error {
+	return nil
+}
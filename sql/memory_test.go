@@ -0,0 +1,222 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"testing"
+)
+
+/*
+The memory service uses the same API as the sqlite service, without needing a database
+*/
+func ExampleNewMemoryService() {
+	service := NewMemoryService()
+	service.Define(&Device{})
+
+	service.Create(&Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 2"})
+	service.Create(&Device{Name: "Device 3"})
+
+	query := matcher.NewStructMatcher()
+	query.AddField("Name", matcher.Neq("Device 2"))
+	device := Device{}
+	next, errf, err := Iterate(service.ReadAllWhere(&device, query))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for next(&device) {
+		fmt.Println(device)
+	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
+
+	//Output:
+	//{1 0 Device 1}
+	//{3 0 Device 3}
+}
+
+func TestMemoryBasicOps(t *testing.T) {
+	type Foo struct {
+		Id int64  `sql:"primary,autoincrement"`
+		A  string `sql:"unique"`
+		B  int64
+	}
+	service := NewMemoryService()
+
+	err := service.Create(&Foo{A: "1st"})
+	if err == nil {
+		t.Error("Expected an error creating a record without a table")
+	}
+	service.Define(&Foo{})
+
+	for i, a := range []string{"1st", "2nd", "3rd"} {
+		err = service.Create(&Foo{Id: 100, A: a, B: int64(i + 1)})
+		if err != nil {
+			t.Errorf("Error creating record %v: %v", a, err)
+		}
+	}
+	err = service.Create(&Foo{A: "1st"})
+	if err == nil || err.Error() != "UNIQUE constraint failed: Foo.A" {
+		t.Errorf("Expected a unique constraint error, got %v", err)
+	}
+	err = service.CreateAll([]Foo{{A: "4th"}, {A: "4th"}})
+	if err == nil {
+		t.Error("Expected a unique constraint error within a batch")
+	}
+
+	temp := Foo{}
+	service.Get(2, &temp)
+	if (temp != Foo{Id: 2, A: "2nd", B: 2}) {
+		t.Errorf("Error retrieving record, got %v", temp)
+	}
+
+	temp.A = "Second"
+	temp.B = 20
+	err = service.Update(&temp)
+	if err != nil {
+		t.Errorf("Error updating record: %v", err)
+	}
+	temp = Foo{}
+	service.Get(2, &temp)
+	if (temp != Foo{Id: 2, A: "Second", B: 20}) {
+		t.Errorf("Error retrieving updated record, got %v", temp)
+	}
+
+	//Autoincrement fields are immutable, so UpdateAll leaves them alone
+	match := matcher.NewStructMatcher()
+	match.AddField("B", matcher.Eq(int64(3)))
+	err = service.UpdateAllWhere(&Foo{Id: 7, A: "Big"}, match)
+	if err != nil {
+		t.Errorf("Error updating records: %v", err)
+	}
+	service.Get(3, &temp)
+	if (temp != Foo{Id: 3, A: "Big", B: 0}) {
+		t.Errorf("Error retrieving updated record, got %v", temp)
+	}
+
+	//Changing both rows to the same unique value fails, and leaves the table as it was
+	err = service.UpdateAll(&Foo{A: "Same"})
+	if err == nil {
+		t.Error("Expected a unique constraint error on update")
+	}
+	service.Get(1, &temp)
+	if temp.A != "1st" {
+		t.Errorf("Failed update was partially applied, got %v", temp)
+	}
+
+	match = matcher.NewStructMatcher()
+	match.AddField("B", matcher.Gt(int64(10)))
+	err = service.DeleteAllWhere(&Foo{}, match)
+	if err != nil {
+		t.Errorf("Error deleting: %v", err)
+	}
+	err = service.DeleteById(1, &Foo{})
+	if err != nil {
+		t.Errorf("Error deleting: %v", err)
+	}
+	next, _, _ := Iterate(service.ReadAll(&Foo{}))
+	count := 0
+	for next(&temp) {
+		count++
+		if temp.Id != 3 {
+			t.Errorf("Unexpected record %v", temp)
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 record, found %v", count)
+	}
+
+	err = service.Create(&Foo{A: "5th"})
+	service.Get(4, &temp)
+	if err != nil || temp.A != "5th" {
+		t.Errorf("Autoincrement should continue after deletes, got %v: %v", temp, err)
+	}
+
+	_, err = service.ReadAllWhere(&Foo{}, matcher.Buggy())
+	if err == nil {
+		t.Error("Expected matcher errors to propagate")
+	}
+}
+
+func TestMemoryOptions(t *testing.T) {
+	type Foo struct {
+		Id int64 `sql:"primary,autoincrement"`
+		A  string
+	}
+	service := NewMemoryService()
+	service.Define(&Foo{})
+	for _, a := range []string{"d", "b", "e", "a", "c"} {
+		service.Create(&Foo{A: a})
+	}
+
+	assertIds := func(message string, options QueryOptions, expected ...int64) {
+		next, _, err := Iterate(service.ReadAll(&Foo{}, options))
+		if err != nil {
+			t.Errorf("%v: %v", message, err)
+			return
+		}
+		found := make([]int64, 0)
+		temp := Foo{}
+		for next(&temp) {
+			found = append(found, temp.Id)
+		}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Errorf("%v: got:%v, want:%v", message, found, expected)
+		}
+	}
+	assertIds("Ascending", QueryOptions{OrderBy: []Order{Asc("A")}}, 4, 2, 5, 1, 3)
+	assertIds("Descending", QueryOptions{OrderBy: []Order{Desc("A")}, Limit: 2}, 3, 1)
+	assertIds("Offset", QueryOptions{OrderBy: []Order{Asc("A")}, Offset: 3}, 1, 3)
+	assertIds("Offset past end", QueryOptions{Offset: 10})
+	assertIds("After", QueryOptions{After: int64(2), Limit: 2}, 3, 4)
+	assertIds("After descending", QueryOptions{After: 4, OrderBy: []Order{Desc("Id")}}, 3, 2, 1)
+}
+
+func TestMemoryJoin(t *testing.T) {
+	service := NewMemoryService()
+	for _, record := range []interface{}{&Peer{}, &Device{}, &DeviceLocation{}, &Object{}} {
+		service.Define(record)
+	}
+	service.Create(&Peer{Name: "Peer"})
+	service.Create(&Device{PeerId: 1, Name: "Bacon"})
+	service.Create(&Device{PeerId: 1, Name: "Pizza"})
+	service.Create(&DeviceLocation{DeviceId: 1, Location: "The kitchen"})
+	service.Create(&Object{DeviceId: 1, Name: "Obj 1"})
+	service.Create(&Object{DeviceId: 2, Name: "Obj 2"})
+	service.Create(&Object{DeviceId: 2, Name: "Obj 3"})
+
+	delegate := service.delegate
+	device := Device{}
+	obj := Object{}
+	location := DeviceLocation{}
+
+	found := make([]string, 0)
+	next, _, err := Iterate(delegate.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for next(&device, &obj) {
+		found = append(found, fmt.Sprintf("%v,%v", device, obj))
+	}
+	expected := "[{1 1 Bacon},{1 1 Obj 1} {2 1 Pizza},{2 2 Obj 2} {2 1 Pizza},{3 2 Obj 3}]"
+	if fmt.Sprint(found) != expected {
+		t.Errorf("got:%v, want:%v", found, expected)
+	}
+
+	found = make([]string, 0)
+	next, _, _ = Iterate(delegate.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &location))
+	for next(&device, &location) {
+		found = append(found, fmt.Sprintf("%v,%v", device, location))
+	}
+	expected = "[{1 1 Bacon},{1 The kitchen}]"
+	if fmt.Sprint(found) != expected {
+		t.Errorf("got:%v, want:%v", found, expected)
+	}
+}
+
+func TestMemoryConformance(t *testing.T) {
+	ConformanceTest(t, NewMemoryService)
+}
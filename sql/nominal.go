@@ -0,0 +1,177 @@
+package records
+
+import (
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"reflect"
+	"strconv"
+)
+
+/*
+This finds the primary key and the field marked nominal for the record type.  Nominal lookups need both, and an integer primary key to fill in Nominal.Id
+*/
+func nominalFields(record interface{}) (primary, nominal goflect.Info, err error) {
+	for _, field := range goflect.GetInfo(record) {
+		if field.IsPrimary {
+			primary = field
+		}
+		if field.IsNominal {
+			nominal = field
+		}
+	}
+	typ, _ := typeAndVal(record)
+	switch {
+	case primary.Name == "":
+		err = RecordError("No primary key found")
+	case nominal.Name == "":
+		err = RecordError("No nominal field found on " + typ.Name())
+	case !isInteger(primary.Kind):
+		err = RecordError("Nominal lookups need an integer primary key, but " + typ.Name() + "." + primary.Name + " is a " + primary.Kind.String())
+	}
+	return primary, nominal, err
+}
+
+func isInteger(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+/*
+This reads the id and name out of a record.  Nominal fields that are not strings are formatted with fmt
+*/
+func nominalOf(val reflect.Value, primary, nominal goflect.Info) Nominal {
+	output := Nominal{Id: integerOf(val.FieldByName(primary.Name))}
+	name := val.FieldByName(nominal.Name)
+	if name.Kind() == reflect.String {
+		output.Name = name.String()
+	} else {
+		output.Name = fmt.Sprint(name.Interface())
+	}
+	return output
+}
+
+/*
+This returns the id and name of every record of the type, ordered by name
+*/
+func (service RecordService) ReadAllNominal(record interface{}, options ...QueryOptions) ([]Nominal, error) {
+	return service.ReadAllNominalWhere(record, matcher.Any(), options...)
+}
+
+/*
+This returns the id and name of the records that match, which is what a dropdown needs.  They are ordered by name, unless the options say otherwise
+*/
+func (service RecordService) ReadAllNominalWhere(record interface{}, match matcher.Matcher, options ...QueryOptions) ([]Nominal, error) {
+	primary, nominal, err := nominalFields(record)
+	if err != nil {
+		return nil, err
+	}
+	option, err := singleOption(options)
+	if err != nil {
+		return nil, err
+	}
+	if len(option.OrderBy) == 0 {
+		option.OrderBy = []Order{Asc(nominal.Name)}
+	}
+	cursor, err := service.ReadAllWhere(record, match, option)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	typ, _ := typeAndVal(record)
+	output := make([]Nominal, 0)
+	for cursor.Next() {
+		row := reflect.New(typ)
+		err = cursor.Scan(row.Interface())
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, nominalOf(row.Elem(), primary, nominal))
+	}
+	return output, cursor.Err()
+}
+
+/*
+This returns the id and name of the records that match, and whose name starts with the prefix, for an autocomplete.  The prefix ignores the case of letters, as STARTS WITH does, and an empty prefix matches everything
+*/
+func (service RecordService) SearchNominal(record interface{}, prefix string, match matcher.Matcher, options ...QueryOptions) ([]Nominal, error) {
+	_, nominal, err := nominalFields(record)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		starts := matcher.NewStructMatcher()
+		starts.AddField(nominal.Name, matcher.StartsWith(prefix))
+		match = matcher.And(match, starts)
+	}
+	return service.ReadAllNominalWhere(record, match, options...)
+}
+
+/*
+This returns the id and name of the record with the primary key.  The id is converted to the type of the primary key, and it will return an error if it does not fit, or if there is no such record
+*/
+func (service RecordService) GetNominal(id int64, record interface{}) (Nominal, error) {
+	primary, _, err := nominalFields(record)
+	if err != nil {
+		return Nominal{}, err
+	}
+	typ, _ := typeAndVal(record)
+	field, _ := typ.FieldByName(primary.Name)
+	key, err := convertValue(id, field.Type)
+	if err != nil {
+		return Nominal{}, RecordError("No " + typ.Name() + " found with " + primary.Name + " " + strconv.FormatInt(id, 10) + ": " + err.Error())
+	}
+	match := matcher.NewStructMatcher()
+	match.AddField(primary.Name, matcher.Eq(key.Interface()))
+	found, err := service.ReadAllNominalWhere(record, match)
+	if err != nil {
+		return Nominal{}, err
+	}
+	if len(found) == 0 {
+		return Nominal{}, RecordError("No " + typ.Name() + " found with " + primary.Name + " " + strconv.FormatInt(id, 10))
+	}
+	return found[0], nil
+}
+
+/*
+This reads the record whose nominal field is the name into the record.  It will return an error if there is no such record, or if the name is shared by several
+*/
+func (service RecordService) GetByNominal(name string, record interface{}) error {
+	_, nominal, err := nominalFields(record)
+	if err != nil {
+		return err
+	}
+	match := matcher.NewStructMatcher()
+	match.AddField(nominal.Name, matcher.Eq(name))
+	cursor, err := service.ReadAllWhere(record, match, QueryOptions{Limit: 2})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	found := 0
+	for cursor.Next() {
+		found++
+		if found > 1 {
+			break
+		}
+		err = cursor.Scan(record)
+		if err != nil {
+			return err
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	typ, _ := typeAndVal(record)
+	switch found {
+	case 0:
+		return RecordError("No " + typ.Name() + " found with " + nominal.Name + " " + strconv.Quote(name))
+	case 1:
+		return nil
+	}
+	return RecordError("Several " + typ.Name() + " records have " + nominal.Name + " " + strconv.Quote(name))
+}
@@ -0,0 +1,77 @@
+package records
+
+import (
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+)
+
+/*
+The nominal field and the primary key are all a dropdown needs.  The names come back in order
+*/
+func ExampleRecordService_ReadAllNominal() {
+	type Color struct {
+		Id   int64  `sql:"primary,autoincrement"`
+		Name string `sql:"unique,nominal"`
+		Hex  string
+	}
+	service := NewMemoryService()
+	service.Define(&Color{})
+	service.CreateAll([]Color{{Name: "Red", Hex: "#f00"}, {Name: "Green", Hex: "#0f0"}, {Name: "Blue", Hex: "#00f"}})
+
+	fmt.Println(service.ReadAllNominal(&Color{}))
+	fmt.Println(service.SearchNominal(&Color{}, "Gr", matcher.Any()))
+	fmt.Println(service.GetNominal(1, &Color{}))
+
+	color := Color{}
+	err := service.GetByNominal("Blue", &color)
+	fmt.Println(color, err)
+
+	//Output:
+	//[{3 Blue} {2 Green} {1 Red}] <nil>
+	//[{2 Green}] <nil>
+	//{1 Red} <nil>
+	//{3 Blue #00f} <nil>
+}
+
+/*
+Nominal lookups need a nominal field and an integer primary key
+*/
+func ExampleRecordService_ReadAllNominal_errors() {
+	type Unnamed struct {
+		Id int64 `sql:"primary"`
+	}
+	type Keyed struct {
+		Key  string `sql:"primary"`
+		Name string `sql:"nominal"`
+	}
+	service := NewMemoryService()
+
+	_, err := service.ReadAllNominal(&Unnamed{})
+	fmt.Println(err)
+	_, err = service.GetNominal(1, &Keyed{})
+	fmt.Println(err)
+
+	//Output:
+	//No nominal field found on Unnamed
+	//Nominal lookups need an integer primary key, but Keyed.Key is a string
+}
+
+/*
+A name that is missing, or shared by several records, cannot be resolved
+*/
+func ExampleRecordService_GetByNominal() {
+	type Tag struct {
+		Id   int64  `sql:"primary,autoincrement"`
+		Name string `sql:"nominal"`
+	}
+	service := NewMemoryService()
+	service.Define(&Tag{})
+	service.CreateAll([]Tag{{Name: "urgent"}, {Name: "urgent"}})
+
+	fmt.Println(service.GetByNominal("later", &Tag{}))
+	fmt.Println(service.GetByNominal("urgent", &Tag{}))
+
+	//Output:
+	//No Tag found with Name "later"
+	//Several Tag records have Name "urgent"
+}
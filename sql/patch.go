@@ -0,0 +1,209 @@
+package records
+
+import (
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"math"
+	"reflect"
+	"sort"
+)
+
+/*
+This is a partial update.  It is passed to Update or UpdateAllWhere in place of a record, and only the fields it names are set.  Build one with Set or Only
+*/
+type Patch struct {
+	record interface{}
+	fields []string
+	err    error
+}
+
+/*
+This builds a patch from a map of field names to values.  The prototype is the type of record to update, and Update matches on its primary key.  Each value must be of the same kind as its field, although integers may be given for any number field they fit in, either size of float for a float field, and a whole float, as decoded JSON holds, for an integer field.  A pointer or a sql.Null field is set to NULL by nil, and to a value of the kind it holds otherwise
+
+    service.UpdateAllWhere(Set(&Device{}, map[string]interface{}{"Port": 22}), match)
+*/
+func Set(prototype interface{}, changes map[string]interface{}) Patch {
+	typ, val := typeAndVal(prototype)
+	record := reflect.New(typ)
+	record.Elem().Set(val)
+	known := make(map[string]goflect.Info)
+	for _, field := range goflect.GetInfo(prototype) {
+		known[field.Name] = field
+	}
+
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, present := known[name]
+		if !present {
+			return Patch{err: RecordError("Unknown update field: " + name)}
+		}
+		target := record.Elem().FieldByName(name)
+		value, err := convertValue(changes[name], target.Type())
+		if err != nil {
+			return Patch{err: RecordError(fmt.Sprintf("Cannot set %v.%v (%v) to %#v: %v", typ.Name(), field.Name, target.Type(), changes[name], err))}
+		}
+		target.Set(value)
+	}
+	return Patch{record: record.Interface(), fields: names}
+}
+
+/*
+This builds a patch that sets only the named fields of the record, which is a field mask.  The other fields of the record are ignored, apart from the primary key that Update matches on
+*/
+func Only(record interface{}, fields ...string) Patch {
+	return Patch{record: record, fields: fields}
+}
+
+/*
+This converts a value from a map to the type of a field.  The kinds must agree, except that an integer may be stored in any number field that holds it exactly, and a float that holds a whole number may be stored in an integer field
+*/
+func convertValue(value interface{}, typ reflect.Type) (reflect.Value, error) {
+	if held, optional := matcher.NullableType(typ); optional {
+		return convertOptional(value, typ, held)
+	}
+	if value == nil {
+		return reflect.Value{}, RecordError("nil is not a value")
+	}
+	val := reflect.ValueOf(value)
+	if val.Type() == typ {
+		return val, nil
+	}
+	if val.Kind() == typ.Kind() && val.Type().ConvertibleTo(typ) {
+		return val.Convert(typ), nil
+	}
+	output := reflect.New(typ).Elem()
+	switch {
+	case isInteger(val.Kind()) && isInteger(typ.Kind()):
+		if isSigned(val.Kind()) {
+			n := val.Int()
+			if isSigned(typ.Kind()) && !output.OverflowInt(n) {
+				output.SetInt(n)
+				return output, nil
+			}
+			if !isSigned(typ.Kind()) && n >= 0 && !output.OverflowUint(uint64(n)) {
+				output.SetUint(uint64(n))
+				return output, nil
+			}
+		} else {
+			n := val.Uint()
+			if isSigned(typ.Kind()) && n <= math.MaxInt64 && !output.OverflowInt(int64(n)) {
+				output.SetInt(int64(n))
+				return output, nil
+			}
+			if !isSigned(typ.Kind()) && !output.OverflowUint(n) {
+				output.SetUint(n)
+				return output, nil
+			}
+		}
+		return reflect.Value{}, RecordError("the value does not fit")
+	case isFloat(val.Kind()) && isInteger(typ.Kind()):
+		//Decoded JSON holds every number as a float64, so a whole number may set an integer field
+		f := val.Float()
+		if f != math.Trunc(f) || math.IsInf(f, 0) {
+			return reflect.Value{}, RecordError("the value is not a whole number")
+		}
+		if isSigned(typ.Kind()) && f >= math.MinInt64 && f < math.MaxInt64 && !output.OverflowInt(int64(f)) {
+			output.SetInt(int64(f))
+			return output, nil
+		}
+		if !isSigned(typ.Kind()) && f >= 0 && f < math.MaxUint64 && !output.OverflowUint(uint64(f)) {
+			output.SetUint(uint64(f))
+			return output, nil
+		}
+		return reflect.Value{}, RecordError("the value does not fit")
+	case isFloat(val.Kind()) && isFloat(typ.Kind()):
+		output.SetFloat(val.Float())
+		return output, nil
+	case isSigned(val.Kind()) && isFloat(typ.Kind()):
+		output.SetFloat(float64(val.Int()))
+		return output, nil
+	case isInteger(val.Kind()) && isFloat(typ.Kind()):
+		output.SetFloat(float64(val.Uint()))
+		return output, nil
+	}
+	return reflect.Value{}, RecordError("the kinds do not match")
+}
+
+/*
+This converts a value for a pointer or a sql.Null field.  Nil sets NULL, and a value of the kind the field holds is converted and wrapped, as is the value held by another pointer or sql.Null type
+*/
+func convertOptional(value interface{}, typ, held reflect.Type) (reflect.Value, error) {
+	output := reflect.New(typ).Elem()
+	if value == nil {
+		return output, nil
+	}
+	val := reflect.ValueOf(value)
+	if val.Type() == typ {
+		return val, nil
+	}
+	if _, optional := matcher.NullableType(val.Type()); optional {
+		inner, valid := nullValue(val)
+		if !valid {
+			return output, nil
+		}
+		val = inner
+	}
+	inner, err := convertValue(val.Interface(), held)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if typ.Kind() == reflect.Ptr {
+		output.Set(reflect.New(held))
+		output.Elem().Set(inner)
+		return output, nil
+	}
+	output.Field(0).Set(inner)
+	output.FieldByName("Valid").SetBool(true)
+	return output, nil
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isSigned(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+/*
+This returns the record and the fields to set for an update.  A plain record sets every field that can change
+*/
+func unpatch(record interface{}) (interface{}, []string, error) {
+	patch, ok := record.(Patch)
+	if !ok {
+		return record, nil, checkStruct(record)
+	}
+	if patch.err != nil {
+		return nil, nil, patch.err
+	}
+	if len(patch.fields) == 0 {
+		return nil, nil, RecordError("No fields to update")
+	}
+	return patch.record, patch.fields, checkStruct(patch.record)
+}
+
+/*
+This checks that an update was given a struct.  A map does not say which type of record it updates, so it must be passed through Set
+*/
+func checkStruct(record interface{}) error {
+	if record == nil {
+		return RecordError("Cannot update from nil, expected a record or a Patch")
+	}
+	typ, _ := typeAndVal(record)
+	if typ.Kind() == reflect.Map {
+		return RecordError(fmt.Sprintf("Cannot update from a %v, use Set to build a Patch from a map", typ))
+	}
+	if typ.Kind() != reflect.Struct {
+		return RecordError(fmt.Sprintf("Cannot update from a %v, expected a record or a Patch", typ))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package records
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+)
+
+/*
+A map sets only the fields it names.  Values are checked against the kinds of the fields before anything is written
+*/
+func ExampleSet() {
+	type Sensor struct {
+		Id    int64 `sql:"primary,autoincrement"`
+		Name  string
+		Limit uint8
+		Scale float32
+	}
+	service := NewMemoryService()
+	service.Define(&Sensor{})
+	service.CreateAll([]Sensor{{Name: "a", Limit: 1, Scale: 1}, {Name: "b", Limit: 2, Scale: 1}})
+
+	match := matcher.NewStructMatcher()
+	match.AddField("Limit", matcher.Gt(uint8(1)))
+	fmt.Println(service.UpdateAllWhere(Set(&Sensor{}, map[string]interface{}{"Limit": 200, "Scale": 0.5}), match))
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Limit": 300})))
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Name": 4})))
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Colour": "red"})))
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{})))
+	fmt.Println(service.UpdateAll(map[string]interface{}{"Limit": 3}))
+
+	//Numbers decoded from JSON are float64, which may set an integer field when they are whole
+	var decoded map[string]interface{}
+	json.Unmarshal([]byte(`{"Limit": 2.5}`), &decoded)
+	fmt.Println(service.UpdateAllWhere(Set(&Sensor{}, decoded), match))
+	json.Unmarshal([]byte(`{"Limit": 20}`), &decoded)
+	fmt.Println(service.UpdateAllWhere(Set(&Sensor{}, decoded), match))
+
+	sensor := Sensor{}
+	next, errf, err := Iterate(service.ReadAll(&sensor))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for next(&sensor) {
+		fmt.Println(sensor)
+	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
+
+	//Output:
+	//<nil>
+	//Cannot set Sensor.Limit (uint8) to 300: the value does not fit
+	//Cannot set Sensor.Name (string) to 4: the kinds do not match
+	//Unknown update field: Colour
+	//No fields to update
+	//Cannot update from a map[string]interface {}, use Set to build a Patch from a map
+	//Cannot set Sensor.Limit (uint8) to 2.5: the value is not a whole number
+	//<nil>
+	//{1 a 1 1}
+	//{2 b 20 0.5}
+}
+
+/*
+Nil sets a pointer or a sql.Null field to NULL, and a value of the kind it holds sets it to that value
+*/
+func ExampleSet_optional() {
+	type Sensor struct {
+		Id    int64 `sql:"primary,autoincrement"`
+		Limit *int32
+		Label sql.NullString
+	}
+	service := NewMemoryService()
+	service.Define(&Sensor{})
+	service.Create(&Sensor{})
+
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Limit": 5, "Label": "hot"})))
+	sensor := Sensor{Id: 1}
+	service.Read(&sensor)
+	fmt.Println(*sensor.Limit, sensor.Label)
+
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Limit": nil, "Label": nil})))
+	service.Read(&sensor)
+	fmt.Println(sensor.Limit == nil, sensor.Label)
+
+	fmt.Println(service.UpdateAll(Set(&Sensor{}, map[string]interface{}{"Limit": "5"})))
+
+	//Output:
+	//<nil>
+	//5 {hot true}
+	//<nil>
+	//true { false}
+	//Cannot set Sensor.Limit (*int32) to "5": the kinds do not match
+}
+
+/*
+A field mask sets only the named fields of the record.  Update still matches on the primary key
+*/
+func ExampleOnly() {
+	type Sensor struct {
+		Id   int64 `sql:"primary,autoincrement"`
+		Name string
+		Note string
+	}
+	service := NewMemoryService()
+	service.Define(&Sensor{})
+	service.Create(&Sensor{Name: "a", Note: "keep"})
+
+	fmt.Println(service.Update(Only(&Sensor{Id: 1, Name: "renamed"}, "Name")))
+	fmt.Println(service.Update(Only(&Sensor{Id: 1}, "Id")))
+	sensor := Sensor{Id: 1}
+	service.Read(&sensor)
+	fmt.Println(sensor)
+
+	//Output:
+	//<nil>
+	//Cannot change immutable field Sensor.Id
+	//{1 renamed keep}
+}
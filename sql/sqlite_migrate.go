@@ -0,0 +1,535 @@
+package records
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+This is a single change to a table, and the statements that make it
+*/
+type MigrationStep struct {
+	Description string
+	Statements  []string
+}
+
+/*
+This is the ordered list of changes needed to bring a table up to date with a record type.  An empty plan means the table is already current
+*/
+type MigrationPlan struct {
+	Table string
+	Steps []MigrationStep
+}
+
+/*
+This renders the plan as a sql script, with each step introduced by a comment
+*/
+func (plan MigrationPlan) String() string {
+	output := ""
+	for _, step := range plan.Steps {
+		output += "-- " + step.Description + "\n"
+		for _, statement := range step.Statements {
+			output += statement + ";\n"
+		}
+	}
+	return output
+}
+
+/*
+This is a column, as reported by PRAGMA table_info
+*/
+type sqliteColumn struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Primary bool
+}
+
+/*
+This is an index on a single column, either as reported by PRAGMA index_list or as declared by a record
+*/
+type sqliteIndex struct {
+	Name    string
+	Column  string
+	Unique  bool
+	Created bool
+}
+
+/*
+This runs a query against the connection, and returns each row as a map of column name to value
+*/
+func (service sqlRecordService) queryMaps(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := service.Conn.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	output := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		vals := make([]interface{}, len(names))
+		addrs := make([]interface{}, len(names))
+		for i := range vals {
+			addrs[i] = &vals[i]
+		}
+		err = rows.Scan(addrs...)
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{})
+		for i, name := range names {
+			if b, ok := vals[i].([]byte); ok {
+				vals[i] = string(b)
+			}
+			row[name] = vals[i]
+		}
+		output = append(output, row)
+	}
+	return output, rows.Err()
+}
+
+func (service sqlRecordService) tableColumns(table string) ([]sqliteColumn, error) {
+	rows, err := service.queryMaps("PRAGMA table_info(`" + table + "`)")
+	if err != nil {
+		return nil, err
+	}
+	output := make([]sqliteColumn, 0, len(rows))
+	for _, row := range rows {
+		output = append(output, sqliteColumn{
+			Name:    fmt.Sprint(row["name"]),
+			Type:    strings.ToLower(fmt.Sprint(row["type"])),
+			NotNull: fmt.Sprint(row["notnull"]) == "1",
+			Primary: fmt.Sprint(row["pk"]) != "0",
+		})
+	}
+	return output, nil
+}
+
+/*
+This lists the single column indexes on the table.  Indexes over several columns are not managed by migrations, so they are left out
+*/
+func (service sqlRecordService) tableIndexes(table string) ([]sqliteIndex, error) {
+	rows, err := service.queryMaps("PRAGMA index_list(`" + table + "`)")
+	if err != nil {
+		return nil, err
+	}
+	output := make([]sqliteIndex, 0, len(rows))
+	for _, row := range rows {
+		index := sqliteIndex{
+			Name:    fmt.Sprint(row["name"]),
+			Unique:  fmt.Sprint(row["unique"]) == "1",
+			Created: fmt.Sprint(row["origin"]) == "c",
+		}
+		columns, err := service.queryMaps("PRAGMA index_info(`" + index.Name + "`)")
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) != 1 {
+			continue
+		}
+		index.Column = fmt.Sprint(columns[0]["name"])
+		output = append(output, index)
+	}
+	return output, nil
+}
+
+func (service sqlRecordService) tableAutoincrement(table string) (bool, error) {
+	rows, err := service.queryMaps("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if err != nil || len(rows) == 0 {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(fmt.Sprint(rows[0]["sql"])), "autoincrement"), nil
+}
+
+/*
+This is a foreign key on a single column, either as reported by PRAGMA foreign_key_list or as declared by a record.  It always refers to the primary key of the parent table
+*/
+type sqliteForeignKey struct {
+	Column   string
+	Parent   string
+	OnDelete string
+}
+
+func (service sqlRecordService) tableForeignKeys(table string) ([]sqliteForeignKey, error) {
+	rows, err := service.queryMaps("PRAGMA foreign_key_list(`" + table + "`)")
+	if err != nil {
+		return nil, err
+	}
+	output := make([]sqliteForeignKey, 0, len(rows))
+	for _, row := range rows {
+		output = append(output, sqliteForeignKey{
+			Column:   fmt.Sprint(row["from"]),
+			Parent:   fmt.Sprint(row["table"]),
+			OnDelete: strings.ToUpper(fmt.Sprint(row["on_delete"])),
+		})
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Column < output[j].Column })
+	return output, nil
+}
+
+/*
+This lists the foreign keys a record declares, one for each child or extension field
+*/
+func sqliteForeignKeys(record interface{}) []sqliteForeignKey {
+	output := make([]sqliteForeignKey, 0)
+	for _, field := range sqliteFields(record) {
+		if parentOf(field) == "" {
+			continue
+		}
+		output = append(output, sqliteForeignKey{Column: columnName(field), Parent: parentOf(field), OnDelete: strings.ToUpper(field.OnDelete)})
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Column < output[j].Column })
+	return output
+}
+
+/*
+This lists the indexes a record declares.  Primary keys are indexed by the table itself
+*/
+func sqliteIndexes(record interface{}, table string) []sqliteIndex {
+	output := make([]sqliteIndex, 0)
+	for _, field := range sqliteFields(record) {
+		if field.IsPrimary || !field.IsIndexed {
+			continue
+		}
+		column := columnName(field)
+		output = append(output, sqliteIndex{Name: "idx_" + table + "_" + column, Column: column, Unique: field.IsUnique, Created: true})
+	}
+	return output
+}
+
+func (index sqliteIndex) createStatement(table string) string {
+	statement := "CREATE INDEX"
+	if index.Unique {
+		statement = "CREATE UNIQUE INDEX"
+	}
+	return statement + " IF NOT EXISTS `" + index.Name + "` ON `" + table + "`(`" + index.Column + "`)"
+}
+
+/*
+This renders the value given to existing rows when a column is added, from the default tag if there is one
+*/
+func sqliteDefault(field goflect.Info) (string, error) {
+	value := field.Default
+	switch sqliteType(field) {
+	case "integer":
+		if field.Kind == reflect.Bool {
+			if value == "" {
+				return "0", nil
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return "", RecordError("Invalid default for " + field.Name + ": " + value)
+			}
+			if b {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		if value == "" {
+			return "0", nil
+		}
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", RecordError("Invalid default for " + field.Name + ": " + value)
+		}
+		return value, nil
+	case "real":
+		if value == "" {
+			return "0", nil
+		}
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "", RecordError("Invalid default for " + field.Name + ": " + value)
+		}
+		return value, nil
+	}
+	return "'" + strings.Replace(value, "'", "''", -1) + "'", nil
+}
+
+/*
+This compares the live table to the record type, and returns the steps needed to bring it up to date.  Columns are added in place when possible.  Anything sqlite cannot alter, such as a removed column or a changed type, primary key or null constraint, rebuilds the table and copies the rows across.  Indexes are created and dropped last
+*/
+func (service sqlRecordService) PlanMigration(record interface{}) (MigrationPlan, error) {
+	typ, _ := typeAndVal(record)
+	table := typ.Name()
+	plan := MigrationPlan{Table: table}
+	if service.dialect.Name() != "sqlite" {
+		return plan, RecordError("Migrations are only supported for sqlite, not " + service.dialect.Name())
+	}
+	fields := sqliteFields(record)
+	desired := sqliteIndexes(record, table)
+
+	columns, err := service.tableColumns(table)
+	if err != nil {
+		return plan, err
+	}
+	if len(columns) == 0 {
+		plan.Steps = append(plan.Steps, MigrationStep{Description: "Create table " + table, Statements: []string{service.CreateStatement(record)}})
+		for _, index := range desired {
+			if !index.Unique {
+				plan.Steps = append(plan.Steps, MigrationStep{Description: "Create index " + index.Name, Statements: []string{index.createStatement(table)}})
+			}
+		}
+		return plan, nil
+	}
+	indexes, err := service.tableIndexes(table)
+	if err != nil {
+		return plan, err
+	}
+
+	existing := make(map[string]sqliteColumn)
+	for _, column := range columns {
+		existing[column.Name] = column
+	}
+	constrained := make(map[string]bool)
+	for _, index := range indexes {
+		if index.Unique && !index.Created {
+			constrained[index.Column] = true
+		}
+	}
+	declared := make(map[string]bool)
+	reasons := make([]string, 0)
+	added := make([]goflect.Info, 0)
+	autoincrement := false
+	for _, field := range fields {
+		name := columnName(field)
+		declared[name] = true
+		autoincrement = autoincrement || field.IsAutoincrement
+		column, present := existing[name]
+		switch {
+		case !present && field.IsPrimary:
+			reasons = append(reasons, "primary key "+name+" added")
+		case !present:
+			added = append(added, field)
+		case !strings.EqualFold(column.Type, sqliteType(field)):
+			reasons = append(reasons, "column "+name+" changed from "+column.Type+" to "+sqliteType(field))
+		case column.Primary != field.IsPrimary:
+			reasons = append(reasons, "column "+name+" changed primary key")
+		case column.NotNull == field.IsNullable:
+			reasons = append(reasons, "column "+name+" changed null constraint")
+		case constrained[name] && !field.IsUnique:
+			reasons = append(reasons, "column "+name+" is no longer unique")
+		}
+	}
+	for _, column := range columns {
+		if !declared[column.Name] {
+			reasons = append(reasons, "column "+column.Name+" removed")
+		}
+	}
+	hadAutoincrement, err := service.tableAutoincrement(table)
+	if err != nil {
+		return plan, err
+	}
+	if hadAutoincrement != autoincrement {
+		reasons = append(reasons, "autoincrement changed")
+	}
+	keys, err := service.tableForeignKeys(table)
+	if err != nil {
+		return plan, err
+	}
+	if fmt.Sprint(keys) != fmt.Sprint(sqliteForeignKeys(record)) {
+		reasons = append(reasons, "foreign keys changed")
+	}
+
+	if len(reasons) > 0 {
+		step, err := service.rebuildStep(record, table, fields, existing)
+		if err != nil {
+			return plan, err
+		}
+		step.Description += ", " + strings.Join(reasons, ", ")
+		plan.Steps = append(plan.Steps, step)
+		//The rebuilt table only has the unique constraints from its create statement
+		indexes = make([]sqliteIndex, 0)
+		for _, index := range desired {
+			if index.Unique {
+				indexes = append(indexes, sqliteIndex{Column: index.Column, Unique: true})
+			}
+		}
+	} else {
+		for _, field := range added {
+			value, err := sqliteDefault(field)
+			if err != nil {
+				return plan, err
+			}
+			statement := "ALTER TABLE `" + table + "` ADD COLUMN `" + columnName(field) + "` " + sqliteType(field)
+			if !field.IsNullable {
+				statement += " not null"
+			}
+			statement += " DEFAULT " + value
+			plan.Steps = append(plan.Steps, MigrationStep{Description: "Add column " + columnName(field), Statements: []string{statement}})
+		}
+	}
+
+	remaining := make([]sqliteIndex, 0)
+	for _, index := range indexes {
+		if index.Created && strings.HasPrefix(index.Name, "idx_"+table+"_") && !matchesIndex(desired, index) {
+			plan.Steps = append(plan.Steps, MigrationStep{Description: "Drop index " + index.Name, Statements: []string{"DROP INDEX `" + index.Name + "`"}})
+			continue
+		}
+		remaining = append(remaining, index)
+	}
+	for _, index := range desired {
+		if !coversIndex(remaining, index) {
+			plan.Steps = append(plan.Steps, MigrationStep{Description: "Create index " + index.Name, Statements: []string{index.createStatement(table)}})
+		}
+	}
+	return plan, nil
+}
+
+/*
+This reports if the index is one of the declared indexes, on the same column with the same uniqueness
+*/
+func matchesIndex(declared []sqliteIndex, index sqliteIndex) bool {
+	for _, other := range declared {
+		if other.Column == index.Column && other.Unique == index.Unique {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+This reports if one of the indexes already serves the declared index.  Any index on the column will do, as long as it is unique when uniqueness is declared
+*/
+func coversIndex(indexes []sqliteIndex, index sqliteIndex) bool {
+	for _, other := range indexes {
+		if other.Column == index.Column && (other.Unique || !index.Unique) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+This follows the procedure sqlite recommends for changes ALTER TABLE cannot make.  A new table is created, the rows are copied into it, and it takes the place of the old one
+*/
+func (service sqlRecordService) rebuildStep(record interface{}, table string, fields []goflect.Info, existing map[string]sqliteColumn) (MigrationStep, error) {
+	temp := "goflect_new_" + table
+	columns := make([]string, 0)
+	values := make([]string, 0)
+	for _, field := range fields {
+		name := columnName(field)
+		column, present := existing[name]
+		value, err := sqliteDefault(field)
+		if err != nil {
+			return MigrationStep{}, err
+		}
+		columns = append(columns, "`"+name+"`")
+		switch {
+		case !present:
+			values = append(values, value)
+		case !column.NotNull && !field.IsNullable:
+			values = append(values, "COALESCE(`"+name+"`, "+value+")")
+		default:
+			values = append(values, "`"+name+"`")
+		}
+	}
+	return MigrationStep{
+		Description: "Rebuild table " + table,
+		Statements: []string{
+			service.createStatement(record, temp),
+			"INSERT INTO `" + temp + "`(" + strings.Join(columns, ", ") + ") SELECT " + strings.Join(values, ", ") + " FROM `" + table + "`",
+			"DROP TABLE `" + table + "`",
+			"ALTER TABLE `" + temp + "` RENAME TO `" + table + "`",
+		},
+	}, nil
+}
+
+/*
+This plans the migration, and applies it in a single transaction.  If any statement fails, the table is left as it was
+
+Dropping a table deletes its rows, which would cascade to any children while foreign keys are enforced.  So, as sqlite recommends, enforcement is switched off on the connection while the plan is applied, and every foreign key is checked before the transaction commits.  This cannot be done inside a transaction, so a migration run in one is refused if it rebuilds a table while foreign keys are enforced
+*/
+func (service sqlRecordService) Migrate(record interface{}) error {
+	plan, err := service.PlanMigration(record)
+	if err != nil || len(plan.Steps) == 0 {
+		return err
+	}
+	ctx := context.Background()
+	db, ok := service.Conn.(*sql.DB)
+	if !ok {
+		enforced, err := service.foreignKeysEnforced()
+		if err != nil {
+			return err
+		}
+		if enforced && plan.rebuilds() {
+			return RecordError("Cannot rebuild table " + plan.Table + " inside a transaction while foreign keys are enforced")
+		}
+		return service.applyPlan(ctx, plan, false)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	local := service.withConn(conn)
+	enforced, err := local.foreignKeysEnforced()
+	if err != nil {
+		return err
+	}
+	if enforced {
+		_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+	}
+	return local.applyPlan(ctx, plan, enforced)
+}
+
+func (service sqlRecordService) foreignKeysEnforced() (bool, error) {
+	rows, err := service.queryMaps("PRAGMA foreign_keys")
+	if err != nil || len(rows) == 0 {
+		return false, err
+	}
+	return fmt.Sprint(rows[0]["foreign_keys"]) == "1", nil
+}
+
+func (plan MigrationPlan) rebuilds() bool {
+	for _, step := range plan.Steps {
+		if strings.HasPrefix(step.Description, "Rebuild table") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+This runs every statement of the plan in one transaction.  When check is set, the foreign keys of the whole database are verified before committing
+*/
+func (service sqlRecordService) applyPlan(ctx context.Context, plan MigrationPlan, check bool) error {
+	tx, err := service.begin(ctx)
+	if err != nil {
+		return err
+	}
+	local := tx.(sqlTransaction)
+	for _, step := range plan.Steps {
+		for _, statement := range step.Statements {
+			_, err = local.Conn.ExecContext(ctx, statement)
+			if err != nil {
+				local.rollback()
+				return RecordError(step.Description + ": " + err.Error())
+			}
+		}
+	}
+	if check {
+		violations, err := local.queryMaps("PRAGMA foreign_key_check")
+		if err != nil {
+			local.rollback()
+			return err
+		}
+		if len(violations) > 0 {
+			local.rollback()
+			return RecordError(fmt.Sprintf("FOREIGN KEY constraint failed: %v rows in %v", len(violations), violations[0]["table"]))
+		}
+	}
+	return local.commit()
+}
@@ -0,0 +1,182 @@
+package records
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+Printing a plan is a dry run.  It shows the statements Migrate would execute, without changing the table
+*/
+func ExampleRecordService_PlanMigration() {
+	c, _ := sql.Open("sqlite3", ":memory:")
+	c.SetMaxOpenConns(1)
+	service := NewSqliteService(c)
+	{
+		type Widget struct {
+			Id   int64 `sql:"primary,autoincrement"`
+			Name string
+		}
+		service.Define(&Widget{})
+		service.Create(&Widget{Name: "Sprocket"})
+	}
+
+	//A later version of the struct adds two fields
+	type Widget struct {
+		Id     int64 `sql:"primary,autoincrement"`
+		Name   string
+		Color  string  `sql:"index" default:"red"`
+		Weight float64 `sql:"not-null"`
+	}
+	plan, err := service.PlanMigration(&Widget{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(plan)
+
+	service.Migrate(&Widget{})
+	widget := Widget{}
+	service.Get(1, &widget)
+	fmt.Println(widget)
+
+	//Output:
+	//-- Add column Color
+	//ALTER TABLE `Widget` ADD COLUMN `Color` string DEFAULT 'red';
+	//-- Add column Weight
+	//ALTER TABLE `Widget` ADD COLUMN `Weight` real not null DEFAULT 0;
+	//-- Create index idx_Widget_Color
+	//CREATE INDEX IF NOT EXISTS `idx_Widget_Color` ON `Widget`(`Color`);
+	//{1 Sprocket red 0}
+}
+
+func TestSqliteMigrate(t *testing.T) {
+	c, _ := sql.Open("sqlite3", ":memory:")
+	c.SetMaxOpenConns(1)
+	service := NewSqliteService(c)
+
+	assertPlan := func(message string, record interface{}, expected ...string) {
+		plan, err := service.PlanMigration(record)
+		if err != nil {
+			t.Errorf("%v: %v", message, err)
+			return
+		}
+		found := make([]string, 0)
+		for _, step := range plan.Steps {
+			found = append(found, step.Description)
+		}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Errorf("%v: got:%v, want:%v", message, found, expected)
+		}
+	}
+	migrate := func(message string, record interface{}) {
+		err := service.Migrate(record)
+		if err != nil {
+			t.Errorf("%v: %v", message, err)
+		}
+		assertPlan(message+" is idempotent", record)
+	}
+
+	{
+		type Gadget struct {
+			Id    int64 `sql:"primary,autoincrement"`
+			Name  string
+			Stale int64 `sql:"index"`
+		}
+		assertPlan("New table", &Gadget{}, "Create table Gadget", "Create index idx_Gadget_Stale")
+		migrate("New table", &Gadget{})
+		service.CreateAll([]Gadget{{Name: "a", Stale: 1}, {Name: "a", Stale: 2}})
+		service.Delete(&Gadget{Id: 2})
+		service.Create(&Gadget{Name: "b"})
+	}
+	{
+		type Gadget struct {
+			Id   int64 `sql:"primary,autoincrement"`
+			Name string
+			Size int64 `sql:"index"`
+		}
+		assertPlan("Removed column", &Gadget{}, "Rebuild table Gadget, column Stale removed", "Create index idx_Gadget_Size")
+		migrate("Removed column", &Gadget{})
+	}
+	{
+		type Gadget struct {
+			Id   int64  `sql:"primary,autoincrement"`
+			Name string `sql:"not-null,index"`
+			Size string
+		}
+		assertPlan("Changed columns", &Gadget{}, "Rebuild table Gadget, column Name changed null constraint, column Size changed from integer to string", "Create index idx_Gadget_Name")
+		migrate("Changed columns", &Gadget{})
+
+		next, _, _ := Iterate(service.ReadAll(&Gadget{}, QueryOptions{OrderBy: []Order{Asc("Id")}}))
+		found := make([]Gadget, 0)
+		temp := Gadget{}
+		for next(&temp) {
+			found = append(found, temp)
+		}
+		expected := []Gadget{{Id: 1, Name: "a", Size: "0"}, {Id: 3, Name: "b", Size: "0"}}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Errorf("Rows were not copied, got:%v, want:%v", found, expected)
+		}
+
+		service.Create(&Gadget{Name: "c"})
+		service.Get(4, &temp)
+		if temp.Name != "c" {
+			t.Errorf("Autoincrement did not survive the rebuild, got %v", temp)
+		}
+	}
+	{
+		type Gadget struct {
+			Id   int64  `sql:"primary,autoincrement"`
+			Name string `sql:"unique"`
+			Size string
+		}
+		//Two rows share a name, so the unique index cannot be created, and the whole plan is rolled back
+		service.Create(&Gadget{Name: "a"})
+		err := service.Migrate(&Gadget{})
+		if err == nil || !strings.Contains(err.Error(), "idx_Gadget_Name") {
+			t.Errorf("Expected the index creation to fail, got %v", err)
+		}
+		assertPlan("Failed migration", &Gadget{}, "Drop index idx_Gadget_Name", "Create index idx_Gadget_Name")
+	}
+	{
+		type Gadget struct {
+			Id   int64
+			Flag bool `default:"maybe"`
+		}
+		_, err := service.PlanMigration(&Gadget{})
+		if err == nil {
+			t.Error("Expected an invalid default to be reported")
+		}
+	}
+
+	{
+		type Gizmo struct {
+			Id   int64  `sql:"primary"`
+			Name string `sql:"unique"`
+		}
+		service.Define(&Gizmo{})
+		assertPlan("Defined tables are current", &Gizmo{})
+	}
+	{
+		type Gizmo struct {
+			Id   int64  `sql:"primary"`
+			Name string `sql:"not-null" sql-column:"gizmo_name"`
+		}
+		assertPlan("Renamed column", &Gizmo{}, "Rebuild table Gizmo, column Name removed")
+	}
+	{
+		type Gizmo struct {
+			Id   int64  `sql:"primary"`
+			Name string `sql:"not-null,index"`
+		}
+		assertPlan("Unique constraint dropped", &Gizmo{}, "Rebuild table Gizmo, column Name is no longer unique", "Create index idx_Gizmo_Name")
+		migrate("Unique constraint dropped", &Gizmo{})
+	}
+
+	err := NewMemoryService().Migrate(&Device{})
+	if err == nil {
+		t.Error("Expected the memory service to report migrations are unsupported")
+	}
+}
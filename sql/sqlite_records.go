@@ -1,17 +1,130 @@
 package records
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"git.sevone.com/sdevlin/goflect.git/goflect"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-type sqliteRecordService struct {
-	Conn *sql.DB
+/*
+This is the part of the database/sql API used by the sql service.  It is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+*/
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+/*
+This is a record service for a sql database.  The dialect decides how each statement is written, and the schema remembers the primary key of each type defined through the service, for the dialects that name it in foreign keys
+*/
+type sqlRecordService struct {
+	Conn      sqlConn
+	dialect   Dialect
+	schema    *sqlSchema
+	batchSize int
+}
+
+type sqlSchema struct {
+	lock      sync.Mutex
+	primaries map[string]string
+}
+
+func newSqlService(conn sqlConn, dialect Dialect) sqlRecordService {
+	return sqlRecordService{Conn: conn, dialect: dialect, schema: &sqlSchema{primaries: make(map[string]string)}}
+}
+
+/*
+This returns a copy of the service that runs its statements on another connection
+*/
+func (service sqlRecordService) withConn(conn sqlConn) sqlRecordService {
+	service.Conn = conn
+	return service
+}
+
+/*
+This returns a copy of the service that inserts at most size rows per statement
+*/
+func (service sqlRecordService) withBatchSize(size int) privateRecordService {
+	service.batchSize = size
+	return service
+}
+
+func (service sqlTransaction) withBatchSize(size int) privateRecordService {
+	service.batchSize = size
+	return service
+}
+
+func (service sqlRecordService) quote(name string) string {
+	return service.dialect.QuoteIdentifier(name)
+}
+
+func (service sqlRecordService) exec(ctx context.Context, statement string, args ...interface{}) (sql.Result, error) {
+	return service.Conn.ExecContext(ctx, rebind(service.dialect, statement), args...)
+}
+
+func (service sqlRecordService) query(ctx context.Context, statement string, args ...interface{}) (*sql.Rows, error) {
+	return service.Conn.QueryContext(ctx, rebind(service.dialect, statement), args...)
+}
+
+/*
+This is a sql service bound to a transaction.  A nested transaction is a savepoint
+*/
+type sqlTransaction struct {
+	sqlRecordService
+	tx        *sql.Tx
+	savepoint string
+}
+
+var savepointCount int64
+
+func (service sqlRecordService) begin(ctx context.Context) (transaction, error) {
+	switch conn := service.Conn.(type) {
+	case interface {
+		BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+	}:
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return sqlTransaction{sqlRecordService: service.withConn(tx), tx: tx}, nil
+	case *sql.Tx:
+		name := fmt.Sprintf("goflect_%v", atomic.AddInt64(&savepointCount, 1))
+		_, err := conn.ExecContext(ctx, "SAVEPOINT "+name)
+		if err != nil {
+			return nil, err
+		}
+		return sqlTransaction{sqlRecordService: service, tx: conn, savepoint: name}, nil
+	}
+	return nil, RecordError("Connection does not support transactions")
+}
+
+func (service sqlTransaction) commit() error {
+	if service.savepoint != "" {
+		_, err := service.tx.Exec("RELEASE SAVEPOINT " + service.savepoint)
+		return err
+	}
+	return service.tx.Commit()
+}
+
+func (service sqlTransaction) rollback() error {
+	if service.savepoint != "" {
+		_, err := service.tx.Exec("ROLLBACK TO SAVEPOINT " + service.savepoint)
+		if err != nil {
+			return err
+		}
+		_, err = service.tx.Exec("RELEASE SAVEPOINT " + service.savepoint)
+		return err
+	}
+	return service.tx.Rollback()
 }
 
 func typeAndVal(record interface{}) (reflect.Type, reflect.Value) {
@@ -48,125 +161,410 @@ func sqliteLookupMap() map[reflect.Kind]string {
 }
 
 /*
-This renders the sql statement to create a table, based on the provided record
+This is the column type used for a field
 */
-func (service sqliteRecordService) CreateStatement(record interface{}) string {
+func sqliteType(field goflect.Info) string {
+	kind, present := sqliteLookupMap()[field.Kind]
+	if !present {
+		kind = "string"
+	}
+	return kind
+}
+
+/*
+This returns the fields of the record that are stored in sqlite, leaving out any that are ignored
+*/
+func sqliteFields(record interface{}) []goflect.Info {
+	output := make([]goflect.Info, 0)
+	for _, field := range goflect.GetInfo(record) {
+		if !field.IsSqlIgnored {
+			output = append(output, field)
+		}
+	}
+	return output
+}
+
+/*
+This is the column a field is stored in, which is its name unless the sql-column tag says otherwise
+*/
+func columnName(field goflect.Info) string {
+	if field.SqlColumn != "" {
+		return field.SqlColumn
+	}
+	return field.Name
+}
+
+/*
+This is the type a field points to, through either the sql-extend or the sql-child tag
+*/
+func parentOf(field goflect.Info) string {
+	if field.Extends != "" {
+		return field.Extends
+	}
+	return field.ChildOf
+}
+
+/*
+This checks the sql-on-delete tag of each field.  It is written into the statement, so only cascade and restrict are accepted
+*/
+func checkOnDelete(record interface{}) error {
 	typ, _ := typeAndVal(record)
+	for _, field := range goflect.GetInfo(record) {
+		if field.OnDelete != "" && field.OnDelete != goflect.SQL_CASCADE && field.OnDelete != goflect.SQL_RESTRICT {
+			return RecordError("Unknown sql-on-delete for " + typ.Name() + "." + field.Name + ": " + strconv.Quote(field.OnDelete) + ", expected " + goflect.SQL_CASCADE + " or " + goflect.SQL_RESTRICT)
+		}
+	}
+	return nil
+}
 
-	fields := goflect.GetInfo(record)
-	lookup := sqliteLookupMap()
-	statement := ""
-	statement += "CREATE TABLE IF NOT EXISTS " + typ.Name() + "("
-	for i, field := range fields {
-		kind, present := lookup[field.Kind]
-		if !present {
-			kind = "string"
+/*
+This maps the field names of the records to their quoted columns, for the where clause printer.  Reads qualify each column with its table, so that joined tables do not clash.  When two records share a field name, the first record wins, and the other is reached with a path such as Location.Id, which every field of a join also has
+*/
+func (service sqlRecordService) columnMap(qualify bool, records ...interface{}) map[string]string {
+	output := make(map[string]string)
+	for _, record := range records {
+		typ, _ := typeAndVal(record)
+		for _, field := range sqliteFields(record) {
+			column := service.quote(columnName(field))
+			if qualify {
+				column = service.quote(typ.Name()) + "." + column
+			}
+			if len(records) > 1 {
+				output[typ.Name()+"."+field.Name] = column
+			}
+			if _, present := output[field.Name]; !present {
+				output[field.Name] = column
+			}
 		}
+	}
+	return output
+}
 
-		statement += "\n\t`" + field.Name + "` " + kind
+/*
+This renders the sql statement to create a table, based on the provided record
+*/
+func (service sqlRecordService) CreateStatement(record interface{}) string {
+	typ, _ := typeAndVal(record)
+	return service.createStatement(record, typ.Name())
+}
+
+/*
+This renders the create statement for the record under another table name, which migrations use to rebuild a table
+*/
+func (service sqlRecordService) createStatement(record interface{}, table string) string {
+	entries := make([]string, 0)
+	for _, field := range sqliteFields(record) {
+		entry := service.quote(columnName(field)) + " " + service.dialect.ColumnType(field)
 		if field.IsPrimary {
-			statement += " primary key"
+			entry += " primary key"
 		}
-		if field.IsAutoincrement {
-			statement += " autoincrement"
+		if field.IsAutoincrement && service.dialect.Autoincrement() != "" {
+			entry += " " + service.dialect.Autoincrement()
+		}
+		if field.IsUnique && !field.IsPrimary {
+			entry += " unique"
 		}
 		if !field.IsNullable {
-			statement += " not null"
+			entry += " not null"
 		}
-		if i != len(fields)-1 {
-			statement += ","
+		entries = append(entries, entry)
+	}
+	for _, index := range sqliteIndexes(record, table) {
+		if entry := service.dialect.TableIndex(index.Name, index.Column); entry != "" && !index.Unique {
+			entries = append(entries, entry)
 		}
 	}
-	statement += "\n)"
-	return statement
+	for _, key := range sqliteForeignKeys(record) {
+		if entry := service.dialect.ForeignKey(key.Column, key.Parent, service.schema.primary(key.Parent), key.OnDelete); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return "CREATE TABLE IF NOT EXISTS " + service.quote(table) + "(\n\t" + strings.Join(entries, ",\n\t") + "\n)"
 }
 
-func (service sqliteRecordService) Define(record interface{}) error {
+/*
+This checks that the dialect can declare every foreign key of the record.  A dialect that must name the parent's primary key only knows it for types defined through the same service, so the parents must be defined first
+*/
+func (service sqlRecordService) checkParents(record interface{}) error {
+	typ, _ := typeAndVal(record)
+	for _, key := range sqliteForeignKeys(record) {
+		if service.dialect.ForeignKey(key.Column, key.Parent, service.schema.primary(key.Parent), key.OnDelete) == "" {
+			return RecordError(service.dialect.Name() + " needs the primary key of " + key.Parent + " for the foreign key " + typ.Name() + "." + key.Column + ", so " + key.Parent + " must be defined through the same service first")
+		}
+	}
+	return nil
+}
+
+/*
+This returns the primary key column of a type defined through the service, or an empty string if it is not known
+*/
+func (schema *sqlSchema) primary(name string) string {
+	if schema == nil {
+		return ""
+	}
+	schema.lock.Lock()
+	defer schema.lock.Unlock()
+	return schema.primaries[name]
+}
+
+func (schema *sqlSchema) define(record interface{}) {
+	if schema == nil {
+		return
+	}
+	typ, _ := typeAndVal(record)
+	schema.lock.Lock()
+	defer schema.lock.Unlock()
+	for _, field := range sqliteFields(record) {
+		if field.IsPrimary {
+			schema.primaries[typ.Name()] = columnName(field)
+		}
+	}
+}
+
+/*
+This creates the table, and an index for every indexed field.  Unique fields are constrained by the table itself
+*/
+func (service sqlRecordService) Define(record interface{}) error {
+	typ, _ := typeAndVal(record)
+	if err := checkOnDelete(record); err != nil {
+		return err
+	}
+	if err := service.checkParents(record); err != nil {
+		return err
+	}
 	statement := service.CreateStatement(record)
-	_, err := service.Conn.Exec(statement)
-	return err
+	_, err := service.exec(context.Background(), statement)
+	if err != nil {
+		return err
+	}
+	for _, index := range sqliteIndexes(record, typ.Name()) {
+		statement = service.dialect.CreateIndex(index.Name, typ.Name(), index.Column, index.Unique)
+		if index.Unique || statement == "" {
+			continue
+		}
+		_, err = service.exec(context.Background(), statement)
+		if err != nil {
+			return err
+		}
+	}
+	service.schema.define(record)
+	return nil
 }
 
-func (service sqliteRecordService) createAll(record interface{}) error {
-	typ, val := typeAndVal(record)
+func (service sqlRecordService) createAll(ctx context.Context, record interface{}) error {
+	return service.insertAll(ctx, record, "")
+}
 
-	typ = reflect.TypeOf(record)
-	for typ.Kind() == reflect.Array || typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
-		typ = typ.Elem()
+func (service sqlRecordService) upsertAll(ctx context.Context, record interface{}, key string) error {
+	return service.insertAll(ctx, record, key)
+}
+
+/*
+This inserts the rows, in as many statements as the batch size and the dialect's limit on bound values need.  Several statements run in a transaction, so the rows are still written all at once, or not at all.  When a key is given, a row whose key is already stored updates that row instead
+*/
+func (service sqlRecordService) insertAll(ctx context.Context, record interface{}, key string) error {
+	_, val := typeAndVal(record)
+	if val.Len() == 0 {
+		return nil
+	}
+	first := val.Index(0).Interface()
+	fields := make([]goflect.Info, 0)
+	for _, field := range sqliteFields(first) {
+		//Generated keys are left to the database, unless the upsert is keyed on them
+		if !field.IsAutoincrement || field.Name == key {
+			fields = append(fields, field)
+		}
 	}
-	fields := goflect.GetInfo(val.Index(0).Interface())
+	size := service.batchRows(len(fields))
+	if val.Len() <= size {
+		return service.insertBatch(ctx, first, fields, val, key)
+	}
+	tx, err := service.begin(ctx)
+	if err != nil {
+		return err
+	}
+	batch := tx.(sqlTransaction).sqlRecordService
+	for start := 0; start < val.Len(); start += size {
+		end := start + size
+		if end > val.Len() {
+			end = val.Len()
+		}
+		err = batch.insertBatch(ctx, first, fields, val.Slice(start, end), key)
+		if err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+	return tx.commit()
+}
+
+/*
+This is the number of rows each INSERT can hold
+*/
+func (service sqlRecordService) batchRows(columns int) int {
+	size := service.dialect.MaxParameters()
+	if columns > 0 {
+		size /= columns
+	}
+	if service.batchSize > 0 && service.batchSize < size {
+		size = service.batchSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (service sqlRecordService) insertBatch(ctx context.Context, record interface{}, fields []goflect.Info, rows reflect.Value, key string) error {
+	typ, _ := typeAndVal(record)
 	statement := ""
-	statement += "INSERT INTO `" + typ.Name() + "`("
+	statement += "INSERT INTO " + service.quote(typ.Name()) + "("
 	columns := make([]string, 0)
+	updates := make([]string, 0)
+	keyColumn := ""
 	for _, field := range fields {
-		if field.IsAutoincrement {
-			continue
+		columns = append(columns, service.quote(columnName(field)))
+		switch {
+		case field.Name == key:
+			keyColumn = columnName(field)
+		case !field.IsPrimary && !field.IsImmutable:
+			updates = append(updates, columnName(field))
 		}
-		columns = append(columns, "`"+field.Name+"`")
 	}
 	statement += strings.Join(columns, ", ")
 	statement += " ) VALUES "
 
 	columns = make([]string, 0)
-	for i := 0; i < val.Len(); i++ {
-		columns = append(columns, uglyGuy(fields, val.Index(i).Interface()))
+	args := make([]interface{}, 0)
+	for i := 0; i < rows.Len(); i++ {
+		placeholders, rowArgs, err := uglyGuy(service.dialect, fields, rows.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		columns = append(columns, placeholders)
+		args = append(args, rowArgs...)
 	}
 	statement += strings.Join(columns, ", ")
-	_, err := service.Conn.Exec(statement)
-	return err
+
+	generated, found := autoincrementField(sqliteFields(record))
+	if key != "" {
+		//The keys of upserted rows are read back by the RecordService, since not every row is inserted
+		statement += " " + service.dialect.Upsert(typ.Name(), updates, []string{keyColumn})
+		found = false
+	}
+	if !found {
+		_, err := service.exec(ctx, statement, args...)
+		return err
+	}
+	ids, err := service.insertIds(ctx, statement, columnName(generated), rows.Len(), args)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		setKey(rowAt(rows, i), generated, id)
+	}
+	return nil
+}
+
+/*
+This runs an INSERT, and returns the keys the database generated for its rows
+*/
+func (service sqlRecordService) insertIds(ctx context.Context, statement, column string, rows int, args []interface{}) ([]int64, error) {
+	returning := service.dialect.Returning(column)
+	if returning == "" {
+		result, err := service.exec(ctx, statement, args...)
+		if err != nil {
+			return nil, err
+		}
+		return service.dialect.InsertedIds(result, rows)
+	}
+	cursor, err := service.query(ctx, statement+" "+returning, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close()
+	ids := make([]int64, 0, rows)
+	for cursor.Next() {
+		var id int64
+		if err = cursor.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, cursor.Err()
 }
 
-func uglyGuy(fields []goflect.Info, record interface{}) string {
+func uglyGuy(dialect Dialect, fields []goflect.Info, record interface{}) (string, []interface{}, error) {
 	_, val := typeAndVal(record)
 	columns := make([]string, 0)
+	args := make([]interface{}, 0)
 	for _, field := range fields {
-		if field.IsAutoincrement {
-			continue
-		}
 		fieldVal := val.FieldByName(field.Name)
-		columns = append(columns, wrap(fieldVal, field))
+		columns = append(columns, "?")
+		arg, err := wrap(dialect, fieldVal, field)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, arg)
 	}
 	statement := strings.Join(columns, ", ")
 	statement = "( " + statement + " )"
-	return statement
+	return statement, args, nil
 }
 
-func (service sqliteRecordService) updateAll(record interface{}, match matcher.Matcher) error {
+func (service sqlRecordService) updateAll(ctx context.Context, record interface{}, fields []string, match matcher.Matcher) error {
 	typ, val := typeAndVal(record)
 
-	fields := goflect.GetInfo(record)
-	statement := "UPDATE " + typ.Name() + " SET "
+	set := make(map[string]bool)
+	for _, name := range fields {
+		set[name] = true
+	}
+	statement := "UPDATE " + service.quote(typ.Name()) + " SET "
 	columns := make([]string, 0)
-	for _, field := range fields {
+	args := make([]interface{}, 0)
+	for _, field := range sqliteFields(record) {
+		if !set[field.Name] {
+			continue
+		}
 		fieldVal := val.FieldByName(field.Name)
-		temp := field.Name + "=" + wrap(fieldVal, field)
-		columns = append(columns, temp)
+		columns = append(columns, service.quote(columnName(field))+" = ?")
+		arg, err := wrap(service.dialect, fieldVal, field)
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+	}
+	if len(columns) == 0 {
+		//Nothing changes, but the statement still checks the table and the matcher
+		column := service.quote(columnName(sqliteFields(record)[0]))
+		columns = append(columns, column+" = "+column)
 	}
 	statement += strings.Join(columns, ", ")
 
-	printer := matcher.NewSqlitePrinter()
-	result, err := printer.Print(match)
+	printer := matcher.NewDialectPrinter(service.dialect, service.columnMap(false, record))
+	result, params, err := printer.PrintParams(match)
 	if err != nil {
 		return err
 	}
 	statement += " WHERE " + result
+	args = append(args, params...)
 
-	_, err = service.Conn.Exec(statement)
+	_, err = service.exec(ctx, statement, args...)
 	return err
 }
 
-func (service sqliteRecordService) deleteAll(record interface{}, match matcher.Matcher) error {
+func (service sqlRecordService) deleteAll(ctx context.Context, record interface{}, match matcher.Matcher) error {
 	typ, _ := typeAndVal(record)
 
-	statement := "DELETE FROM " + typ.Name()
+	statement := "DELETE FROM " + service.quote(typ.Name())
 
-	printer := matcher.NewSqlitePrinter()
-	result, err := printer.Print(match)
+	printer := matcher.NewDialectPrinter(service.dialect, service.columnMap(false, record))
+	result, params, err := printer.PrintParams(match)
 	if err != nil {
 		return err
 	}
 	statement += " WHERE " + result
-	_, err = service.Conn.Exec(statement)
+	_, err = service.exec(ctx, statement, params...)
 	return err
 }
 
@@ -216,122 +614,299 @@ func determineEdges(records []interface{}) (output []Edge) {
 	return output
 }
 
-func (service sqliteRecordService) readAll(query matcher.Matcher, records ...interface{}) (func(record ...interface{}) bool, error) {
+func (service sqlRecordService) readAll(ctx context.Context, query matcher.Matcher, options QueryOptions, records ...interface{}) (*Cursor, error) {
+	primary, err := options.validate(records[0])
+	if err != nil {
+		return nil, err
+	}
 	statement := "SELECT "
 	//path := make(edge
 	types := make([]reflect.Type, 0, 0)
 	columns := make([]string, 0)
+	qualified := make(map[string]string)
 	for _, record := range records {
 		typ, _ := typeAndVal(record)
 
-		fields := goflect.GetInfo(record)
+		fields := sqliteFields(record)
 		for _, field := range fields {
-			columns = append(columns, typ.Name()+".`"+field.Name+"`")
+			column := service.quote(typ.Name()) + "." + service.quote(columnName(field))
+			columns = append(columns, column)
+			qualified[typ.Name()+"."+field.Name] = column
 		}
 		types = append(types, typ)
 
 	}
 	statement += strings.Join(columns, " , ")
-	if len(types) == 1 {
-		statement += " FROM " + types[0].Name()
-	} else {
-		statement += " FROM " + types[0].Name()
+	statement += " FROM " + service.quote(types[0].Name())
+	if len(types) > 1 {
 		edges := determineEdges(records)
 		for _, edge := range edges {
-			statement += fmt.Sprintf(" INNER JOIN %v ON %v = %v", edge.Table, edge.A, edge.B)
+			statement += fmt.Sprintf(" INNER JOIN %v ON %v = %v", service.quote(edge.Table), qualified[edge.A], qualified[edge.B])
 		}
 	}
 
-	printer := matcher.NewSqlitePrinter()
-	result, err := printer.Print(query)
+	printer := matcher.NewDialectPrinter(service.dialect, service.columnMap(true, records...))
+	result, params, err := printer.PrintParams(query)
 	if err != nil {
 		return nil, err
 	}
-	statement += " WHERE " + result
+	statement += " WHERE (" + result + ")"
+	suffix, suffixParams := service.options(options, service.columnMap(true, records[0]), primary)
+	statement += suffix
+	params = append(params, suffixParams...)
 
-	rows, err := service.Conn.Query(statement)
+	rows, err := service.query(ctx, statement, params...)
 	if err != nil {
 		fmt.Println(statement)
 		return nil, err
 	}
 
-	output := func(r ...interface{}) bool {
-		return nextRow(rows, r...)
+	return newCursor(&sqlRows{ctx: ctx, types: types, rows: rows}), nil
+}
+
+type sqlRows struct {
+	ctx       context.Context
+	types     []reflect.Type
+	rows      *sql.Rows
+	cancelled bool
+}
+
+func (source *sqlRows) next() bool {
+	//database/sql closes the rows when the context is done, but not before a buffered row can slip through
+	if source.ctx.Err() != nil {
+		source.cancelled = true
+		return false
 	}
+	return source.rows.Next()
+}
 
-	return output, nil
+func (source *sqlRows) scan(records ...interface{}) error {
+	err := checkRecords(source.types, records)
+	if err != nil {
+		return err
+	}
+	return scanRow(source.rows, records...)
 }
 
-func wrap(fieldVal reflect.Value, field goflect.Info) string {
-	output := ""
-	switch fieldVal.Kind() {
-	case reflect.Bool:
-		if fieldVal.Bool() {
-			output = "1"
+func (source *sqlRows) err() error {
+	if source.cancelled {
+		return source.ctx.Err()
+	}
+	return source.rows.Err()
+}
+
+func (source *sqlRows) close() error {
+	return source.rows.Close()
+}
+
+/*
+This renders the keyset cursor, ORDER BY, LIMIT and OFFSET that follow the WHERE clause.  The cursor is appended with AND, so the where clause must already be in place
+*/
+func (service sqlRecordService) options(options QueryOptions, columns map[string]string, primary goflect.Info) (string, []interface{}) {
+	statement := ""
+	params := make([]interface{}, 0)
+	if options.After != nil {
+		if options.afterDescending() {
+			statement += " AND " + columns[primary.Name] + " < ?"
 		} else {
-			output = "0"
+			statement += " AND " + columns[primary.Name] + " > ?"
+		}
+		params = append(params, options.After)
+	}
+
+	orders := make([]string, 0)
+	primaryOrdered := false
+	for _, order := range options.OrderBy {
+		column := columns[order.Field]
+		if order.Descending {
+			column += " DESC"
+		} else {
+			column += " ASC"
+		}
+		primaryOrdered = primaryOrdered || order.Field == primary.Name
+		orders = append(orders, column)
+	}
+	if options.After != nil && !primaryOrdered {
+		orders = append(orders, columns[primary.Name]+" ASC")
+	}
+	if len(orders) > 0 {
+		statement += " ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	if options.Limit > 0 || options.Offset > 0 {
+		var limit interface{} = options.Limit
+		if options.Limit == 0 {
+			limit = service.dialect.NoLimit()
+		}
+		statement += " LIMIT ? OFFSET ?"
+		params = append(params, limit, options.Offset)
+	}
+	return statement, params
+}
+
+/*
+This converts a field to the value that is bound to its placeholder.  Values are never spliced into the statement, so no quoting or escaping is required.  Bools are bound as the dialect stores them, and a uint64 too large for an int64 is an error rather than a negative number
+*/
+func wrap(dialect Dialect, fieldVal reflect.Value, field goflect.Info) (interface{}, error) {
+	if field.IsOptional {
+		held, valid := nullValue(fieldVal)
+		if !valid {
+			return nil, nil
 		}
+		fieldVal = held
+	}
+	if t, ok := fieldVal.Interface().(time.Time); ok {
+		//Times are text that sorts in time order, so the where clause can compare them
+		return t.UTC().Format(matcher.SqlTimeLayout), nil
+	}
+	var output interface{}
+	switch fieldVal.Kind() {
+	case reflect.Bool:
+		output = dialect.BindBool(fieldVal.Bool())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		output = "" + strconv.FormatInt(int64(fieldVal.Uint()), 10) + ""
+		if fieldVal.Uint() > math.MaxInt64 {
+			return nil, RecordError(fmt.Sprintf("The value %v of field %v is too large to store", fieldVal.Uint(), field.Name))
+		}
+		output = int64(fieldVal.Uint())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		output = "" + strconv.FormatInt(fieldVal.Int(), 10) + ""
-	case reflect.Float32:
-		output = "" + strconv.FormatFloat(fieldVal.Float(), 'f', 10, 32) + ""
-	case reflect.Float64:
-		output = "" + strconv.FormatFloat(fieldVal.Float(), 'f', 10, 64) + ""
+		output = fieldVal.Int()
+	case reflect.Float32, reflect.Float64:
+		output = fieldVal.Float()
 	default:
-		output = "\"" + fieldVal.String() + "\""
+		output = fieldVal.String()
 	}
-	return output
+	return output, nil
 }
 
-func nextRow(rows *sql.Rows, records ...interface{}) bool {
-	next := rows.Next()
-	if next {
-		total := 0
-		coerce := func(v interface{}, fVal reflect.Value) {
-			localVal := reflect.ValueOf(v)
-			if fVal.Type() != localVal.Type() {
-				localVal = localVal.Convert(fVal.Type())
-			}
-			fVal.Set(localVal)
+/*
+This converts a value returned by the driver to the type of the field, and sets it
+*/
+func coerce(v interface{}, fieldVal reflect.Value, field goflect.Info) error {
+	if field.IsOptional {
+		return coerceNull(v, fieldVal, field)
+	}
+	if v == nil {
+		return RecordError(fmt.Sprintf("Cannot convert NULL to %v for field %v", fieldVal.Type(), field.Name))
+	}
+	localVal := reflect.ValueOf(v)
+	switch {
+	case fieldVal.Type() == reflect.TypeOf(time.Time{}):
+		t, err := scanTime(v)
+		if err != nil {
+			return RecordError(fmt.Sprintf("Cannot convert %#v to time for field %v: %v", v, field.Name, err))
 		}
-
-		for _, record := range records {
-			fields := goflect.GetInfo(record)
-			total += len(fields)
+		localVal = reflect.ValueOf(t)
+	case field.Kind == reflect.Bool:
+		//PostgreSQL has a boolean type, the others store a number
+		switch b := v.(type) {
+		case bool:
+			localVal = reflect.ValueOf(b)
+		case int64:
+			localVal = reflect.ValueOf(b != 0)
+		default:
+			return RecordError(fmt.Sprintf("Cannot convert %T to bool for field %v", v, field.Name))
 		}
-
-		vals := make([]interface{}, total)
-		addrs := make([]interface{}, total)
-		for i, _ := range vals {
-			addrs[i] = &vals[i]
+	case field.Kind == reflect.String && localVal.Kind() != reflect.String && localVal.Kind() != reflect.Slice:
+		//Columns without a text affinity hand back numbers, which Convert would treat as runes
+		localVal = reflect.ValueOf(fmt.Sprint(v))
+	}
+	if fieldVal.Type() != localVal.Type() {
+		if !localVal.Type().ConvertibleTo(fieldVal.Type()) {
+			return RecordError(fmt.Sprintf("Cannot convert %T to %v for field %v", v, fieldVal.Type(), field.Name))
 		}
+		localVal = localVal.Convert(fieldVal.Type())
+	}
+	fieldVal.Set(localVal)
+	return nil
+}
 
-		rows.Scan(addrs...)
+/*
+This returns the value held by a pointer or a sql.Null field, and false when the field is NULL
+*/
+func nullValue(fieldVal reflect.Value) (reflect.Value, bool) {
+	if fieldVal.Kind() == reflect.Ptr {
+		return fieldVal.Elem(), !fieldVal.IsNil()
+	}
+	return fieldVal.Field(0), fieldVal.FieldByName("Valid").Bool()
+}
 
-		offset := 0
-		for _, record := range records {
-			fields := goflect.GetInfo(record)
-			val := reflect.ValueOf(record)
-			if val.Kind() == reflect.Ptr {
-				val = val.Elem()
-			}
+/*
+This sets a pointer or a sql.Null field.  NULL leaves the field nil or not Valid, and anything else is converted to the type the field holds
+*/
+func coerceNull(v interface{}, fieldVal reflect.Value, field goflect.Info) error {
+	if v == nil {
+		fieldVal.Set(reflect.Zero(fieldVal.Type()))
+		return nil
+	}
+	held := reflect.New(fieldVal.Type()).Elem()
+	if fieldVal.Kind() == reflect.Ptr {
+		held = reflect.New(fieldVal.Type().Elem())
+	}
+	target, _ := nullValue(held)
+	field.IsOptional = false
+	if err := coerce(v, target, field); err != nil {
+		return err
+	}
+	if fieldVal.Kind() != reflect.Ptr {
+		held.FieldByName("Valid").SetBool(true)
+	}
+	fieldVal.Set(held)
+	return nil
+}
 
-			for i, field := range fields {
-				fieldVal := val.FieldByName(field.Name)
-				switch field.Kind {
-				case reflect.Bool:
-					coerce(vals[offset+i].(int64) != 0, fieldVal)
-				default:
-					coerce(vals[offset+i], fieldVal)
-				}
-			}
-			offset += len(fields)
+/*
+This reads a time written by wrap.  Drivers hand text back as a string or as bytes, and some parse time columns themselves
+*/
+func scanTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC(), nil
+	case []byte:
+		return time.Parse(matcher.SqlTimeLayout, string(t))
+	case string:
+		return time.Parse(matcher.SqlTimeLayout, t)
+	}
+	return time.Time{}, RecordError(fmt.Sprintf("unexpected %T", v))
+}
+
+/*
+This scans the current row into the records, one column per field
+*/
+func scanRow(rows *sql.Rows, records ...interface{}) error {
+	total := 0
+	for _, record := range records {
+		fields := sqliteFields(record)
+		total += len(fields)
+	}
+
+	vals := make([]interface{}, total)
+	addrs := make([]interface{}, total)
+	for i, _ := range vals {
+		addrs[i] = &vals[i]
+	}
+
+	err := rows.Scan(addrs...)
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	for _, record := range records {
+		fields := sqliteFields(record)
+		val := reflect.ValueOf(record)
+		if val.Kind() == reflect.Ptr {
+			val = val.Elem()
 		}
 
+		for i, field := range fields {
+			err = coerce(vals[offset+i], val.FieldByName(field.Name), field)
+			if err != nil {
+				return err
+			}
+		}
+		offset += len(fields)
 	}
-	return next
+	return nil
 }
 
 func RailsConvention(record interface{}) func(interface{}) (interface{}, error) {
@@ -1,6 +1,7 @@
 package records
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"git.sevone.com/sdevlin/goflect.git/goflect"
@@ -12,7 +13,7 @@ import (
 )
 
 /*
-This is a basic example showing how the metadata maps to a sqlite service table creation.  It needs to be fixed to allow unique keys to be respected
+This is a basic example showing how the metadata maps to a sqlite service table creation
 */
 func ExampleSqlDefiner_sqliteBasic() {
 	type Foo struct {
@@ -27,9 +28,9 @@ func ExampleSqlDefiner_sqliteBasic() {
 	fmt.Println(sqlService.CreateStatement(Foo{}))
 
 	//Output:
-	//CREATE TABLE IF NOT EXISTS Foo(
+	//CREATE TABLE IF NOT EXISTS `Foo`(
 	//	`Id` integer primary key autoincrement not null,
-	//	`A` string not null,
+	//	`A` string unique not null,
 	//	`B` integer
 	//)
 }
@@ -59,7 +60,7 @@ func ExampleRecordService_createSqliteVerbose() {
 	if err == nil {
 		fmt.Println("Record Createed properly")
 	}
-	next, err := service.ReadAll(&foo)
+	next, errf, err := Iterate(service.ReadAll(&foo))
 	if err == nil {
 		fmt.Println("Records read properly")
 	}
@@ -71,10 +72,13 @@ func ExampleRecordService_createSqliteVerbose() {
 	for next(&newFoo) {
 		fmt.Println(newFoo)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//Table created properly
-	//{0 Hello World 10}
+	//{1 Hello World 10}
 	//Record Createed properly
 	//Records read properly
 	//{1 Hello World 10}
@@ -103,7 +107,7 @@ func ExampleRecordService_createSqliteIdiomatic() {
 	if err != nil {
 		return
 	}
-	next, err := service.ReadAll(&foo)
+	next, errf, err := Iterate(service.ReadAll(&foo))
 	if err != nil {
 		return
 	}
@@ -111,6 +115,9 @@ func ExampleRecordService_createSqliteIdiomatic() {
 	for next(&newFoo) {
 		fmt.Println(newFoo)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//{1 Hello World 10}
@@ -147,7 +154,7 @@ func ExampleRecordService_CreateAll_infoTest() {
 	fmt.Println(info)
 
 	//Output:
-	//{{Name string} {true false true false true false false false    } {} {This is the name of the field in the struct.  It is authoritative 0 false false  }}
+	//{{Name string false} {true false true false true false false false     } {} {This is the name of the field in the struct.  It is authoritative 0 false false  }}
 
 }
 
@@ -159,7 +166,7 @@ This shows the power of the rails convention transform in action
 //service := tableCreationBoilerplate()
 
 ////create our first device
-//service.Create(Device{Name: "Device 1"})
+//service.Create(&Device{Name: "Device 1"})
 
 ////Print all the devices
 //device := Device{}
@@ -217,7 +224,7 @@ func TestBasicTableOpsFoo(t *testing.T) {
 		t.Error("Error Retrieving Record")
 	}
 
-	next, _ := service.ReadAll(&Foo{})
+	next, _, _ := Iterate(service.ReadAll(&Foo{}))
 	for next(&temp) {
 		if temp.Id != temp.B {
 			t.Error(fmt.Sprintf("Error with autoincrement, Id: %v B: %v", temp.Id, temp.B))
@@ -226,7 +233,7 @@ func TestBasicTableOpsFoo(t *testing.T) {
 
 	match := matcher.NewStructMatcher()
 	match.AddField("B", matcher.Eq(1))
-	next, _ = service.ReadAllWhere(&Foo{}, match)
+	next, _, _ = Iterate(service.ReadAllWhere(&Foo{}, match))
 
 	for next(&temp) {
 		if temp.A != "1st" {
@@ -270,7 +277,7 @@ func basicWriteHelper(t *testing.T, retrieved, expected interface{}) {
 		t.Error("Error on first record equality")
 	}
 
-	next, _ := service.ReadAll(retrieved)
+	next, _, _ := Iterate(service.ReadAll(retrieved))
 	i := 0
 	for next(retrieved) {
 		i++
@@ -295,7 +302,7 @@ func basicWriteHelper(t *testing.T, retrieved, expected interface{}) {
 	}
 	service.Delete(retrieved)
 
-	next, _ = service.ReadAll(retrieved)
+	next, _, _ = Iterate(service.ReadAll(retrieved))
 	i = 0
 	for next(retrieved) {
 		i++
@@ -306,7 +313,7 @@ func basicWriteHelper(t *testing.T, retrieved, expected interface{}) {
 
 	service.DeleteById(2, retrieved)
 
-	next, _ = service.ReadAll(retrieved)
+	next, _, _ = Iterate(service.ReadAll(retrieved))
 	i = 0
 	for next(retrieved) {
 		i++
@@ -341,6 +348,24 @@ func TestBasicTableOpsUints(t *testing.T) {
 	basicWriteHelper(t, &Baz{}, &Baz{})
 }
 
+func TestSqliteLargeUint(t *testing.T) {
+	type Huge struct {
+		Id  int64 `sql:"primary,autoincrement"`
+		U64 uint64
+	}
+	c, _ := sql.Open("sqlite3", ":memory:")
+	service := NewSqliteService(c)
+	if err := service.Define(&Huge{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Create(&Huge{U64: 1 << 63}); err == nil {
+		t.Errorf("Expected a uint64 above MaxInt64 to be refused, rather than stored as a negative number")
+	}
+	if err := service.Create(&Huge{U64: 1<<63 - 1}); err != nil {
+		t.Errorf("Expected MaxInt64 to be stored, got %v", err)
+	}
+}
+
 func TestBasicTableOpsFloats(t *testing.T) {
 	type Baz struct {
 		Id  int64 `sql:"primary,autoincrement"`
@@ -409,7 +434,7 @@ func TestSQLEmbedAttributes(t *testing.T) {
 func TestBasicJoin(t *testing.T) {
 	c, _ := sql.Open("sqlite3", ":memory:")
 	service := NewSqliteService(c)
-	sqlService, _ := service.delegate.(sqliteRecordService)
+	sqlService, _ := service.delegate.(sqlRecordService)
 	err := sqlService.Define(&Device{})
 	if err != nil {
 		fmt.Println("Table create error")
@@ -452,7 +477,7 @@ func TestBasicJoin(t *testing.T) {
 	ind := Indicator{}
 	peer := Peer{}
 
-	next, err := sqlService.readAll(matcher.Any(), &device, &obj)
+	next, _, err := Iterate(sqlService.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &obj))
 	if err != nil {
 		t.Errorf("Error executing stuff, %v", err)
 	}
@@ -460,7 +485,7 @@ func TestBasicJoin(t *testing.T) {
 		fmt.Printf("%v,%v\n", device, obj)
 	}
 
-	next, err = sqlService.readAll(matcher.Any(), &device, &location)
+	next, _, err = Iterate(sqlService.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &location))
 	if err != nil {
 		t.Errorf("Error executing stuff, %v", err)
 	}
@@ -468,14 +493,14 @@ func TestBasicJoin(t *testing.T) {
 		fmt.Printf("%v,%v\n", device, location)
 	}
 
-	next, err = sqlService.readAll(matcher.Any(), &device, &location, &obj)
+	next, _, err = Iterate(sqlService.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &location, &obj))
 	if err != nil {
 		t.Errorf("Error executing stuff, %v", err)
 	}
 	for next(&device, &location, &obj) {
 		fmt.Printf("%v,%v,%v\n", device, location, obj)
 	}
-	next, err = sqlService.readAll(matcher.Any(), &device, &obj, &ind)
+	next, _, err = Iterate(sqlService.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &obj, &ind))
 	if err != nil {
 		t.Errorf("Error executing stuff, %v", err)
 	}
@@ -483,7 +508,7 @@ func TestBasicJoin(t *testing.T) {
 		fmt.Printf("%v,%v,%v\n", device, obj, ind)
 	}
 
-	next, err = sqlService.readAll(matcher.Any(), &device, &peer)
+	next, _, err = Iterate(sqlService.readAll(context.Background(), matcher.Any(), QueryOptions{}, &device, &peer))
 	if err != nil {
 		t.Errorf("Error executing stuff, %v", err)
 	}
@@ -493,3 +518,394 @@ func TestBasicJoin(t *testing.T) {
 	//Output:
 	//Bacon
 }
+
+func TestSqliteQuotedValues(t *testing.T) {
+	type Foo struct {
+		Id int64 `sql:"primary,autoincrement"`
+		A  string
+	}
+
+	c, _ := sql.Open("sqlite3", ":memory:")
+	service := NewSqliteService(c)
+	sqlService, _ := service.delegate.(Definer)
+	err := sqlService.Define(&Foo{})
+	if err != nil {
+		t.Error("Miss creating table")
+	}
+
+	values := []string{`It's`, `"Quoted"`, `'); DROP TABLE Foo; --`}
+	for _, value := range values {
+		err = service.Create(&Foo{A: value})
+		if err != nil {
+			t.Errorf("Could not create record %v: %v", value, err)
+		}
+	}
+
+	for i, value := range values {
+		temp := Foo{}
+		err = service.Get(int64(i+1), &temp)
+		if err != nil || temp.A != value {
+			t.Errorf("Error retrieving record, got %v want %v: %v", temp.A, value, err)
+		}
+
+		match := matcher.NewStructMatcher()
+		match.AddField("A", matcher.Eq(value))
+		next, _, err := Iterate(service.ReadAllWhere(&Foo{}, match))
+		if err != nil {
+			t.Errorf("Error reading where A = %v: %v", value, err)
+			continue
+		}
+		count := 0
+		for next(&temp) {
+			count++
+		}
+		if count != 1 {
+			t.Errorf("Expected one record for %v, found %v", value, count)
+		}
+	}
+
+	temp := Foo{Id: 1, A: `Still "quoted"`}
+	err = service.Update(&temp)
+	if err != nil {
+		t.Errorf("Error updating record: %v", err)
+	}
+	match := matcher.NewStructMatcher()
+	match.AddField("A", matcher.In([]string{`Still "quoted"`, `'); DROP TABLE Foo; --`}))
+	err = service.DeleteAllWhere(&Foo{}, match)
+	if err != nil {
+		t.Errorf("Error deleting records: %v", err)
+	}
+	next, _, _ := Iterate(service.ReadAll(&Foo{}))
+	count := 0
+	for next(&temp) {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected one record to remain, found %v", count)
+	}
+}
+
+/*
+QueryOptions can be used to page through a large table.  The After cursor picks up where the last page left off
+*/
+func ExampleRecordService_ReadAllWhere_paging() {
+	service := tableCreationBoilerplate()
+	for i := 1; i <= 7; i++ {
+		service.Create(&Device{Name: fmt.Sprintf("Device %v", i)})
+	}
+
+	device := Device{}
+	options := QueryOptions{Limit: 3}
+	for page := 1; ; page++ {
+		next, errf, err := Iterate(service.ReadAll(&device, options))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		count := 0
+		for next(&device) {
+			fmt.Println("Page", page, device)
+			count++
+		}
+		if err := errf(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if count < 3 {
+			break
+		}
+		options.After = device.Id
+	}
+
+	//Output:
+	//Page 1 {1 0 Device 1}
+	//Page 1 {2 0 Device 2}
+	//Page 1 {3 0 Device 3}
+	//Page 2 {4 0 Device 4}
+	//Page 2 {5 0 Device 5}
+	//Page 2 {6 0 Device 6}
+	//Page 3 {7 0 Device 7}
+}
+
+func TestSqliteQueryOptions(t *testing.T) {
+	type Foo struct {
+		Id   int64 `sql:"primary,autoincrement"`
+		A    string
+		B    int64
+		Memo string `sql:"ignored"`
+	}
+
+	c, _ := sql.Open("sqlite3", ":memory:")
+	service := NewSqliteService(c)
+	err := service.Define(&Foo{})
+	if err != nil {
+		t.Error("Miss creating table")
+	}
+	for i, a := range []string{"d", "b", "e", "a", "c"} {
+		service.Create(&Foo{A: a, B: int64(i % 2)})
+	}
+
+	assertIds := func(message string, match matcher.Matcher, options QueryOptions, expected ...int64) {
+		next, _, err := Iterate(service.ReadAllWhere(&Foo{}, match, options))
+		if err != nil {
+			t.Errorf("%v: %v", message, err)
+			return
+		}
+		found := make([]int64, 0)
+		temp := Foo{}
+		for next(&temp) {
+			found = append(found, temp.Id)
+		}
+		if fmt.Sprint(found) != fmt.Sprint(expected) {
+			t.Errorf("%v: got:%v, want:%v", message, found, expected)
+		}
+	}
+	assertError := func(message string, options QueryOptions) {
+		_, err := service.ReadAll(&Foo{}, options)
+		if err == nil {
+			t.Errorf("%v: expected an error", message)
+		}
+	}
+
+	odd := matcher.NewStructMatcher()
+	odd.AddField("B", matcher.Eq(1))
+	either := matcher.NewStructMatcher()
+	either.AddField("A", matcher.Or(matcher.Eq("a"), matcher.Eq("e")))
+
+	assertIds("Ascending", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("A")}}, 4, 2, 5, 1, 3)
+	assertIds("Descending", matcher.Any(), QueryOptions{OrderBy: []Order{Desc("A")}}, 3, 1, 5, 2, 4)
+	assertIds("Multiple", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("B"), Desc("A")}}, 3, 1, 5, 2, 4)
+	assertIds("Limit", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("A")}, Limit: 2}, 4, 2)
+	assertIds("Offset", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("A")}, Offset: 3}, 1, 3)
+	assertIds("Limit and Offset", matcher.Any(), QueryOptions{OrderBy: []Order{Asc("A")}, Limit: 1, Offset: 1}, 2)
+	assertIds("Where", odd, QueryOptions{OrderBy: []Order{Desc("A")}}, 2, 4)
+	assertIds("After", matcher.Any(), QueryOptions{After: int64(2), Limit: 2}, 3, 4)
+	assertIds("After descending", matcher.Any(), QueryOptions{After: int64(4), OrderBy: []Order{Desc("Id")}}, 3, 2, 1)
+	assertIds("After with or", either, QueryOptions{After: int64(3)}, 4)
+
+	assertError("Unknown field", QueryOptions{OrderBy: []Order{Asc("Bacon")}})
+	assertError("Ignored field", QueryOptions{OrderBy: []Order{Asc("Memo")}})
+	assertError("Negative limit", QueryOptions{Limit: -1})
+	_, err = service.ReadAll(&Foo{}, QueryOptions{}, QueryOptions{})
+	if err == nil {
+		t.Error("Expected an error for multiple options")
+	}
+}
+
+func TestSqliteConformance(t *testing.T) {
+	ConformanceTest(t, func() RecordService {
+		c, _ := sql.Open("sqlite3", "file::memory:?_foreign_keys=1")
+		//Every connection to :memory: is a new database, so keep to one
+		c.SetMaxOpenConns(1)
+		return NewSqliteService(c)
+	})
+}
+
+/*
+Fields can be stored under another column name with sql-column, or left out of the table entirely with the ignored flag
+*/
+func ExampleSqlDefiner_sqliteColumns() {
+	type Foo struct {
+		Id    int64  `sql:"primary,autoincrement" sql-column:"foo_id"`
+		A     string `sql:"index" sql-column:"a_column"`
+		Cache string `sql:"ignored"`
+	}
+
+	c, _ := sql.Open("sqlite3", ":memory:")
+	service := NewSqliteService(c)
+	sqlService, _ := service.delegate.(SqlDefiner)
+	fmt.Println(sqlService.CreateStatement(Foo{}))
+
+	//Output:
+	//CREATE TABLE IF NOT EXISTS `Foo`(
+	//	`foo_id` integer primary key autoincrement not null,
+	//	`a_column` string
+	//)
+}
+
+func TestSqliteColumns(t *testing.T) {
+	type Bar struct {
+		Id   int64  `sql:"primary,autoincrement" sql-column:"bar_id"`
+		Name string `sql:"unique" sql-column:"bar_name"`
+	}
+	type Foo struct {
+		Id    int64  `sql:"primary,autoincrement" sql-column:"foo_id"`
+		BarId int64  `sql-child:"Bar" sql-column:"bar"`
+		A     string `sql:"index" sql-column:"a_column"`
+		Cache string `sql:"ignored"`
+	}
+
+	c, _ := sql.Open("sqlite3", ":memory:")
+	c.SetMaxOpenConns(1)
+	service := NewSqliteService(c)
+	for _, record := range []interface{}{&Bar{}, &Foo{}} {
+		if err := service.Define(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delegate := service.delegate.(sqlRecordService)
+	indexes, _ := delegate.tableIndexes("Foo")
+	if fmt.Sprint(indexes) != "[{idx_Foo_a_column a_column false true}]" {
+		t.Errorf("Unexpected indexes %v", indexes)
+	}
+
+	service.CreateAll([]Bar{{Name: "x"}, {Name: "y"}})
+	if err := service.Create(&Bar{Name: "x"}); err == nil {
+		t.Error("Expected a unique constraint error")
+	}
+	service.CreateAll([]Foo{{BarId: 1, A: "c", Cache: "lost"}, {BarId: 2, A: "b"}, {BarId: 1, A: "a"}})
+
+	match := matcher.NewStructMatcher()
+	match.AddField("A", matcher.Neq("b"))
+	match.AddField("BarId", matcher.Lte(match.Field("Id")))
+	next, _, err := Iterate(service.ReadAllWhere(&Foo{}, match, QueryOptions{OrderBy: []Order{Asc("A")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make([]Foo, 0)
+	temp := Foo{}
+	for next(&temp) {
+		found = append(found, temp)
+	}
+	expected := []Foo{{Id: 3, BarId: 1, A: "a"}, {Id: 1, BarId: 1, A: "c"}}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		t.Errorf("got:%v, want:%v", found, expected)
+	}
+
+	temp = Foo{Id: 2, BarId: 2, A: "B", Cache: "lost"}
+	if err = service.Update(&temp); err != nil {
+		t.Error(err)
+	}
+	match = matcher.NewStructMatcher()
+	match.AddField("A", matcher.Eq("c"))
+	if err = service.DeleteAllWhere(&Foo{}, match); err != nil {
+		t.Error(err)
+	}
+	temp = Foo{}
+	bar := Bar{}
+	next, _, err = Iterate(delegate.readAll(context.Background(), matcher.Any(), QueryOptions{After: int64(0)}, &temp, &bar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found = make([]Foo, 0)
+	for next(&temp, &bar) {
+		found = append(found, temp)
+		if bar.Id != temp.BarId {
+			t.Errorf("Joined the wrong record %v to %v", bar, temp)
+		}
+	}
+	expected = []Foo{{Id: 2, BarId: 2, A: "B"}, {Id: 3, BarId: 1, A: "a"}}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		t.Errorf("got:%v, want:%v", found, expected)
+	}
+}
+
+/*
+Child and extension fields become foreign keys.  Sqlite only enforces them on connections opened with _foreign_keys=1
+*/
+func ExampleSqlDefiner_sqliteForeignKeys() {
+	type Gear struct {
+		Id      int64 `sql:"primary,autoincrement"`
+		PeerId  int64 `sql-child:"Peer"`
+		OwnerId int64 `sql-child:"Peer" sql-on-delete:"cascade"`
+	}
+
+	c, _ := sql.Open("sqlite3", ":memory:")
+	service := NewSqliteService(c)
+	sqlService, _ := service.delegate.(SqlDefiner)
+	fmt.Println(sqlService.CreateStatement(Gear{}))
+	fmt.Println(sqlService.CreateStatement(DeviceLocation{}))
+
+	//Output:
+	//CREATE TABLE IF NOT EXISTS `Gear`(
+	//	`Id` integer primary key autoincrement not null,
+	//	`PeerId` integer,
+	//	`OwnerId` integer,
+	//	FOREIGN KEY (`OwnerId`) REFERENCES `Peer` ON DELETE CASCADE,
+	//	FOREIGN KEY (`PeerId`) REFERENCES `Peer` ON DELETE RESTRICT
+	//)
+	//CREATE TABLE IF NOT EXISTS `DeviceLocation`(
+	//	`DeviceId` integer primary key not null,
+	//	`Location` string,
+	//	FOREIGN KEY (`DeviceId`) REFERENCES `Device` ON DELETE CASCADE
+	//)
+}
+
+func TestSqliteForeignKeys(t *testing.T) {
+	type Shelf struct {
+		Id   int64 `sql:"primary,autoincrement"`
+		Name string
+	}
+	type Book struct {
+		Id      int64 `sql:"primary,autoincrement"`
+		ShelfId int64 `sql-child:"Shelf"`
+	}
+
+	c, _ := sql.Open("sqlite3", "file::memory:?_foreign_keys=1")
+	c.SetMaxOpenConns(1)
+	service := NewSqliteService(c)
+	if err := service.DefineAll(&Book{}, &Shelf{}); err != nil {
+		t.Fatal(err)
+	}
+	service.Create(&Shelf{Name: "Top"})
+	service.CreateAll([]Book{{ShelfId: 1}, {ShelfId: 1}})
+	if err := service.Create(&Book{ShelfId: 2}); err == nil {
+		t.Error("Expected a missing shelf to be rejected")
+	}
+
+	{
+		type Shelf struct {
+			Id   int64  `sql:"primary,autoincrement"`
+			Name string `sql:"not-null"`
+		}
+		//A rebuild drops the table, which must not cascade or fail while foreign keys are enforced
+		if err := service.Transaction(func(tx RecordService) error { return tx.Migrate(&Shelf{}) }); err == nil {
+			t.Error("Expected a rebuild inside a transaction to be refused")
+		}
+		if err := service.Migrate(&Shelf{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := service.Create(&Book{ShelfId: 2}); err == nil {
+			t.Error("Expected foreign keys to be enforced after the migration")
+		}
+	}
+	next, _, _ := Iterate(service.ReadAll(&Book{}))
+	count := 0
+	for next(&Book{}) {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected the books to survive the rebuild, found %v", count)
+	}
+
+	{
+		type Book struct {
+			Id      int64 `sql:"primary,autoincrement"`
+			ShelfId int64 `sql-child:"Shelf" sql-on-delete:"cascade"`
+		}
+		plan, err := service.PlanMigration(&Book{})
+		if err != nil || len(plan.Steps) != 1 || plan.Steps[0].Description != "Rebuild table Book, foreign keys changed" {
+			t.Errorf("Expected a rebuild for the changed foreign key, got %v %v", plan.Steps, err)
+		}
+		if err = service.Migrate(&Book{}); err != nil {
+			t.Fatal(err)
+		}
+		service.DeleteById(1, &Shelf{})
+		next, _, _ := Iterate(service.ReadAll(&Book{}))
+		if next(&Book{}) {
+			t.Error("Expected the books to be deleted with their shelf")
+		}
+	}
+
+	type Hen struct {
+		Id    int64 `sql:"primary"`
+		EggId int64 `sql-child:"Egg"`
+	}
+	type Egg struct {
+		Id    int64 `sql:"primary"`
+		HenId int64 `sql-child:"Hen"`
+	}
+	if err := NewMemoryService().DefineAll(&Hen{}, &Egg{}, &Shelf{}); err == nil {
+		t.Error("Expected a cycle to be reported")
+	}
+}
@@ -4,6 +4,7 @@ This is a package for abstracting connection to record sources.  It is designed
 package records
 
 import (
+	"context"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
 )
 
@@ -16,22 +17,106 @@ type SqlDefiner interface {
 	CreateStatement(record interface{}) string
 }
 
+/*
+This is implemented by services that can bring existing storage up to date with a record type.  PlanMigration is a dry run, and its result can be printed to review the statements that Migrate would execute
+*/
+type Migrator interface {
+	PlanMigration(record interface{}) (MigrationPlan, error)
+	Migrate(record interface{}) error
+}
+
+/*
+This is returned when an update names a field that is written once.  Primary keys, autoincremented fields and fields tagged immutable cannot change after the record is created
+*/
+type ImmutableFieldError struct {
+	Type  string
+	Field string
+}
+
+func (e ImmutableFieldError) Error() string {
+	return "Cannot change immutable field " + e.Type + "." + e.Field
+}
+
 type RecordError string
 
 func (e RecordError) Error() string {
 	return string(e)
 }
 
+/*
+This is the id and name of a record, taken from its primary key and the field marked nominal.  It is what a dropdown or an autocomplete needs to show a record, and to refer to it
+*/
 type Nominal struct {
 	Id   int64
 	Name string
 }
 
+/*
+This is a single entry in an ORDER BY clause
+*/
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+/*
+This orders the results by the named field, smallest first
+*/
+func Asc(field string) Order {
+	return Order{Field: field}
+}
+
+/*
+This orders the results by the named field, largest first
+*/
+func Desc(field string) Order {
+	return Order{Field: field, Descending: true}
+}
+
+/*
+This controls the order and size of the result set returned by ReadAllWhere.  The zero value returns everything, in whatever order the service prefers
+
+After is a keyset cursor.  When it is set, only records whose primary key comes after it are returned, in the order of the primary key.  The primary key is ascending unless it is the only field in OrderBy, and descending there.  Ordering by any other field is an error, since the cursor cannot say where a page ended in that order.  Pass the primary key of the last record of a page to get the next page, which stays fast on large tables where Offset does not
+*/
+type QueryOptions struct {
+	OrderBy []Order
+	Limit   int64
+	Offset  int64
+	After   interface{}
+}
+
 type privateRecordService interface {
-	createAll(rows interface{}) error
-	readAll(query matcher.Matcher, record ...interface{}) (func(record ...interface{}) bool, error)
-	updateAll(record interface{}, match matcher.Matcher) error
-	deleteAll(record interface{}, match matcher.Matcher) error
+	createAll(ctx context.Context, rows interface{}) error
+	//This creates the rows, or updates the stored rows that have the same value in the key field
+	upsertAll(ctx context.Context, rows interface{}, key string) error
+	readAll(ctx context.Context, query matcher.Matcher, options QueryOptions, record ...interface{}) (*Cursor, error)
+	//This sets the named fields of the records that match to the values in the record
+	updateAll(ctx context.Context, record interface{}, fields []string, match matcher.Matcher) error
+	deleteAll(ctx context.Context, record interface{}, match matcher.Matcher) error
+}
+
+/*
+This is implemented by services that write rows in batches of a configurable size
+*/
+type batchedService interface {
+	withBatchSize(size int) privateRecordService
+}
+
+/*
+This is implemented by services that can group operations so that they apply all at once, or not at all
+*/
+type transactionalService interface {
+	privateRecordService
+	begin(ctx context.Context) (transaction, error)
+}
+
+/*
+This is a service scoped to a single transaction.  Beginning a transaction on it nests a new one inside it
+*/
+type transaction interface {
+	transactionalService
+	commit() error
+	rollback() error
 }
 
 /*
@@ -39,15 +124,5 @@ This is yet another take on the active record pattern, centered around the match
 */
 type RecordService struct {
 	delegate privateRecordService
+	validate bool
 }
-
-//ReadAllNominalWhere(record interface{}, conditions map[string]interface{}) func(record *Nominal) bool
-//GetByNominal(name string, record interface{})
-//DeleteById(id int64, record interface{})
-//GetNominalByNominal(name string) (output Nominal)
-//Get(id int64, record interface{})
-//GetNominal(id int64) (output Nominal)
-//ReadAllNominal(record interface{}) (func(record *Nominal) bool, error)
-//Limit(int) (RecordService error)
-//Offset(int) (RecordService error)
-//OrderBy(...string) (RecordService error)
@@ -57,12 +57,19 @@ func printAll(service RecordService, record interface{}) {
 	if typ.Kind() == reflect.Ptr {
 		typ = typ.Elem()
 	}
-	next, _ := service.ReadAll(record)
+	next, errf, err := Iterate(service.ReadAll(record))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println(typ.Name() + "s")
 	for next(record) {
 		val := reflect.ValueOf(record)
 		fmt.Printf("%v\n", val.Elem().Interface())
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 }
 
 /*
@@ -96,17 +103,24 @@ func Example_reference() {
 		if typ.Kind() == reflect.Ptr {
 			typ = typ.Elem()
 		}
-		next, _ := service.ReadAll(record)
+		next, errf, err := Iterate(service.ReadAll(record))
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
 		fmt.Println(typ.Name() + "s")
 		for next(record) {
 			val := reflect.ValueOf(record)
 			fmt.Println(val.Elem().Interface())
 		}
+		if err := errf(); err != nil {
+			fmt.Println(err)
+		}
 	}
 
 	//A quick example of using the functions to print records
 	service := tableCreationBoilerplate()
-	service.Create(Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 1"})
 
 	device := Device{}
 	printAll(service, &device)
@@ -124,16 +138,23 @@ func Example_1() {
 	service := tableCreationBoilerplate()
 
 	//Insert a device into the database
-	service.Create(Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 1"})
 
 	device := Device{}
 	//Create an iterator over all of the devices
-	next, _ := service.ReadAll(device)
+	next, errf, err := Iterate(service.ReadAll(device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Devices")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//Devices
@@ -148,41 +169,62 @@ func Example_2() {
 	service := tableCreationBoilerplate()
 
 	//Insert a device into the database
-	service.Create(Device{Name: "Device 1"})
-	service.Create(Device{Name: "Device 2"})
-	service.Create(Device{Name: "Device 3"})
+	service.Create(&Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 2"})
+	service.Create(&Device{Name: "Device 3"})
 
 	device := Device{}
 	//Create an iterator over all of the devices
-	next, _ := service.ReadAll(device)
+	next, errf, err := Iterate(service.ReadAll(device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Devices")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Let's find device 1
 	//We'll need to create a matcher the describes the set that this device is in
 	query := matcher.NewStructMatcher()
 	query.AddField("Name", matcher.Eq("Device 1"))
-	next, _ = service.ReadAllWhere(device, query)
+	next, errf, err = Iterate(service.ReadAllWhere(device, query))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	fmt.Println("Our device")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Finding a device that doesn't exist will have the iterator quit immediately
 	query = matcher.NewStructMatcher()
 	query.AddField("Name", matcher.Eq("DOES NOT EXIST"))
-	next, _ = service.ReadAllWhere(device, query)
+	next, errf, err = Iterate(service.ReadAllWhere(device, query))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	fmt.Println("No Devices Match Query")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//Devices
@@ -202,16 +244,23 @@ func Example_3() {
 	service := tableCreationBoilerplate()
 
 	//Insert a device into the database
-	service.Create(Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 1"})
 
 	device := Device{}
 	//Create an iterator over all of the devices
-	next, _ := service.ReadAll(device)
+	next, errf, err := Iterate(service.ReadAll(device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Device at the start")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Passing the record in by reference is very important.  It allows the API to be more flexible, which we'll see later
 	service.ReadById(1, &device)
@@ -219,12 +268,19 @@ func Example_3() {
 	device.Name = "A New Name"
 	service.Update(&device)
 
-	next, _ = service.ReadAll(device)
+	next, errf, err = Iterate(service.ReadAll(device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Device at the end")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//Device at the start
@@ -241,28 +297,42 @@ func Example_4() {
 	service := tableCreationBoilerplate()
 
 	//Insert a device into the database
-	service.Create(Device{Name: "Device 1"})
+	service.Create(&Device{Name: "Device 1"})
 
 	//And now we insert an object into the database
-	service.Create(Object{Name: "Object 1", DeviceId: 1})
+	service.Create(&Object{Name: "Object 1", DeviceId: 1})
 
 	device := Device{}
 	//Create an iterator over all of the devices
-	next, _ := service.ReadAll(device)
+	next, errf, err := Iterate(service.ReadAll(device))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Devices")
 	//Iterate over the deivces
 	for next(&device) {
 		fmt.Println(device)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	object := Object{}
 	//Create an iterator over all of the objects
-	next, _ = service.ReadAll(object)
+	next, errf, err = Iterate(service.ReadAll(object))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	fmt.Println("Objects")
 	//Iterate over the deivces
 	for next(&object) {
 		fmt.Println(object)
 	}
+	if err := errf(); err != nil {
+		fmt.Println(err)
+	}
 
 	//Output:
 	//Devices
@@ -1,10 +1,12 @@
 package records
 
 import (
+	"context"
 	"database/sql"
 	"git.sevone.com/sdevlin/goflect.git/goflect"
 	"git.sevone.com/sdevlin/goflect.git/matcher"
 	"reflect"
+	"strings"
 )
 
 func primaryMatcher(record interface{}) (matcher.Matcher, error) {
@@ -26,71 +28,617 @@ func primaryMatcher(record interface{}) (matcher.Matcher, error) {
 	return nil, RecordError("No primary key found")
 }
 
+/*
+This returns the autoincremented field of the record, if it has one
+*/
+func autoincrementField(fields []goflect.Info) (goflect.Info, bool) {
+	for _, field := range fields {
+		if field.IsAutoincrement {
+			return field, true
+		}
+	}
+	return goflect.Info{}, false
+}
+
+/*
+This returns the record at an index of a slice.  It can be set when the caller will see the change, which is the case for a slice of structs or of pointers
+*/
+func rowAt(val reflect.Value, i int) reflect.Value {
+	row := val.Index(i)
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		row = row.Elem()
+	}
+	return row
+}
+
+/*
+This writes a generated key into the record.  Records the caller cannot see are left alone
+*/
+func setKey(row reflect.Value, field goflect.Info, id int64) {
+	target := row.FieldByName(field.Name)
+	if target.CanSet() {
+		target.Set(reflect.ValueOf(id).Convert(target.Type()))
+	}
+}
+
+/*
+This makes sure the key generated for each record can be written back into it.  Records without an autoincremented field can be passed by value
+*/
+func checkWritable(action string, records reflect.Value) error {
+	if records.Len() == 0 {
+		return nil
+	}
+	first := rowAt(records, 0)
+	field, found := autoincrementField(goflect.GetInfo(first.Interface()))
+	if !found {
+		return nil
+	}
+	for i := 0; i < records.Len(); i++ {
+		if !rowAt(records, i).CanSet() {
+			return RecordError(action + " needs a pointer to " + first.Type().Name() + ", so that the generated " + field.Name + " can be written back")
+		}
+	}
+	return nil
+}
+
+/*
+This checks a single record for checkWritable.  Wrapping it in a slice would make a copy the caller cannot see
+*/
+func checkPointer(action string, record interface{}) error {
+	if reflect.ValueOf(record).Kind() == reflect.Ptr {
+		return nil
+	}
+	return checkWritable(action, reflect.ValueOf([]interface{}{record}))
+}
+
+/*
+This reads an integer field of any size as an int64
+*/
+func integerOf(val reflect.Value) int64 {
+	switch val.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(val.Uint())
+	}
+	return val.Int()
+}
+
+/*
+This creates the storage for the record type, if the underlying service needs it.  It will return an error if the service cannot define records
+*/
+func (service RecordService) Define(record interface{}) error {
+	definer, ok := service.delegate.(Definer)
+	if !ok {
+		return RecordError("Service does not support Define")
+	}
+	return definer.Define(record)
+}
+
+/*
+This defines a group of record types, parents before the children and extensions that point to them, so every foreign key refers to a table that already exists.  Otherwise the records are defined in the order given.  Relationships to types outside the group are left alone, and a cycle is an error
+*/
+func (service RecordService) DefineAll(records ...interface{}) error {
+	ordered, err := dependencyOrder(records)
+	if err != nil {
+		return err
+	}
+	for _, record := range ordered {
+		err = service.Define(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+This sorts the records so that each comes after the types it points to with sql-child or sql-extend.  It keeps the given order where it can
+*/
+func dependencyOrder(records []interface{}) ([]interface{}, error) {
+	names := make([]string, len(records))
+	positions := make(map[string]int)
+	for i, record := range records {
+		typ, _ := typeAndVal(record)
+		names[i] = typ.Name()
+		positions[typ.Name()] = i
+	}
+	parents := make([][]int, len(records))
+	for i, record := range records {
+		for _, field := range goflect.GetInfo(record) {
+			j, present := positions[parentOf(field)]
+			if present && j != i {
+				parents[i] = append(parents[i], j)
+			}
+		}
+	}
+
+	output := make([]interface{}, 0, len(records))
+	done := make([]bool, len(records))
+	for len(output) < len(records) {
+		progress := false
+		for i, record := range records {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range parents[i] {
+				ready = ready && done[j]
+			}
+			if ready {
+				output = append(output, record)
+				done[i] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			cycle := make([]string, 0)
+			for i, name := range names {
+				if !done[i] {
+					cycle = append(cycle, name)
+				}
+			}
+			return nil, RecordError("Record relationships form a cycle between " + strings.Join(cycle, ", "))
+		}
+	}
+	return output, nil
+}
+
+/*
+This returns the steps needed to bring the storage for the record type up to date, without changing anything.  It will return an error if the service cannot migrate records
+*/
+func (service RecordService) PlanMigration(record interface{}) (MigrationPlan, error) {
+	migrator, ok := service.delegate.(Migrator)
+	if !ok {
+		return MigrationPlan{}, RecordError("Service does not support migrations")
+	}
+	return migrator.PlanMigration(record)
+}
+
+/*
+This brings the storage for the record type up to date, creating it if needed.  It will return an error if the service cannot migrate records
+*/
+func (service RecordService) Migrate(record interface{}) error {
+	migrator, ok := service.delegate.(Migrator)
+	if !ok {
+		return RecordError("Service does not support migrations")
+	}
+	return migrator.Migrate(record)
+}
+
+/*
+This runs the callback against a service scoped to a transaction.  The transaction is committed when the callback returns nil, and rolled back when it returns an error or panics.  A panic is passed on after the rollback.
+
+Calling Transaction on the tx service nests a transaction, which can be rolled back without affecting the outer one
+*/
+func (service RecordService) Transaction(fn func(tx RecordService) error) error {
+	return service.TransactionContext(context.Background(), fn)
+}
+
+/*
+This is Transaction, with a context.  The transaction is rolled back by the database if the context is done before it is committed
+*/
+func (service RecordService) TransactionContext(ctx context.Context, fn func(tx RecordService) error) (err error) {
+	delegate, ok := service.delegate.(transactionalService)
+	if !ok {
+		return RecordError("Service does not support transactions")
+	}
+	tx, err := delegate.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.rollback()
+			panic(r)
+		}
+	}()
+	err = fn(RecordService{delegate: tx, validate: service.validate})
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+	return tx.commit()
+}
+
 /*
 This method will delete the record specified by its primary key.  It will return an error if there is no primary key specified, or something goes wrong at a lower layer
 */
 func (service RecordService) Delete(record interface{}) error {
+	return service.DeleteContext(context.Background(), record)
+}
+
+/*
+This is Delete, with a context that can cancel the operation
+*/
+func (service RecordService) DeleteContext(ctx context.Context, record interface{}) error {
 	match, err := primaryMatcher(record)
 	if err != nil {
 		return err
 	}
-	return service.delegate.deleteAll(record, match)
+	return service.delegate.deleteAll(ctx, record, match)
 }
 
 /*
 This method will delete all of the records associated with the record service
 */
 func (service RecordService) DeleteAll(record interface{}) error {
-	return service.delegate.deleteAll(record, matcher.Any())
+	return service.DeleteAllWhereContext(context.Background(), record, matcher.Any())
 }
 
 /*
 This method will delete all of the records associated with the record service, as restricted by the matcher
 */
 func (service RecordService) DeleteAllWhere(record interface{}, match matcher.Matcher) error {
-	return service.delegate.deleteAll(record, match)
+	return service.DeleteAllWhereContext(context.Background(), record, match)
+}
+
+/*
+This is DeleteAllWhere, with a context that can cancel the operation
+*/
+func (service RecordService) DeleteAllWhereContext(ctx context.Context, record interface{}, match matcher.Matcher) error {
+	return service.delegate.deleteAll(ctx, record, match)
 }
 
 /*
-This method will update the record specified by its primary key.  It will return an error if there is no primary key specified, or something goes wrong at a lower layer
+This method will update the record specified by its primary key.  Fields that are written once, the primary key, autoincremented and immutable fields, are left as they are.  Pass a Patch to set only some of the fields.  It will return an error if there is no primary key specified, or something goes wrong at a lower layer
 */
 func (service RecordService) Update(record interface{}) error {
+	return service.UpdateContext(context.Background(), record)
+}
+
+/*
+This is Update, with a context that can cancel the operation
+*/
+func (service RecordService) UpdateContext(ctx context.Context, record interface{}) error {
+	record, fields, err := unpatch(record)
+	if err != nil {
+		return err
+	}
 	match, err := primaryMatcher(record)
 	if err != nil {
 		return err
 	}
-	return service.delegate.updateAll(record, match)
+	return service.updateWhere(ctx, record, fields, match)
 }
 
 /*
-This method will update all of the records associated with the record service to use the values in the record.  Really should only be combined with a dictionary, as to limit the fields that are updated
+This updates only the named fields of the record specified by its primary key.  Naming a field that is written once, such as the primary key or an immutable field, returns an ImmutableFieldError and changes nothing
+*/
+func (service RecordService) UpdateFields(record interface{}, fields ...string) error {
+	return service.UpdateFieldsContext(context.Background(), record, fields...)
+}
+
+/*
+This is UpdateFields, with a context that can cancel the operation
+*/
+func (service RecordService) UpdateFieldsContext(ctx context.Context, record interface{}, fields ...string) error {
+	return service.UpdateContext(ctx, Only(record, fields...))
+}
+
+/*
+This sets the fields of the records that match.  With no names, every field that can change is set
+*/
+func (service RecordService) updateWhere(ctx context.Context, record interface{}, names []string, match matcher.Matcher) error {
+	fields, err := updateFields(record, names)
+	if err != nil {
+		return err
+	}
+	err = service.checkRecords(sliceOf(record), names)
+	if err != nil {
+		return err
+	}
+	return service.delegate.updateAll(ctx, record, fields, match)
+}
+
+/*
+This checks the names of the fields an update sets, or lists every field that can change when there are none.  Fields that are written once are left out of a full update, but naming one is an error
+*/
+func updateFields(record interface{}, names []string) ([]string, error) {
+	typ, _ := typeAndVal(record)
+	fields := goflect.GetInfo(record)
+	if len(names) == 0 {
+		output := make([]string, 0, len(fields))
+		for _, field := range fields {
+			if !writeOnce(field) {
+				output = append(output, field.Name)
+			}
+		}
+		return output, nil
+	}
+	known := make(map[string]goflect.Info)
+	for _, field := range fields {
+		known[field.Name] = field
+	}
+	for _, name := range names {
+		field, present := known[name]
+		if !present {
+			return nil, RecordError("Unknown update field: " + name)
+		}
+		if writeOnce(field) {
+			return nil, ImmutableFieldError{Type: typ.Name(), Field: name}
+		}
+	}
+	return names, nil
+}
+
+/*
+This reports if a field keeps the value it was created with
+*/
+func writeOnce(field goflect.Info) bool {
+	return field.IsImmutable || field.IsPrimary
+}
+
+/*
+This method will update all of the records associated with the record service to use the values in the record.  Really should only be combined with a Patch, from Set or Only, as to limit the fields that are updated
 */
 func (service RecordService) UpdateAll(record interface{}) error {
-	return service.delegate.updateAll(record, matcher.Any())
+	return service.UpdateAllWhereContext(context.Background(), record, matcher.Any())
 }
 
 /*
-This method will update all of the records associated with the record service to use the values in the record, as restricted by teh matcher.  Really should only be combined with a dictionary, as to limit the fields that are updated
+This method will update all of the records associated with the record service to use the values in the record, as restricted by teh matcher.  Really should only be combined with a Patch, from Set or Only, as to limit the fields that are updated
 */
 func (service RecordService) UpdateAllWhere(record interface{}, match matcher.Matcher) error {
-	return service.delegate.updateAll(record, match)
+	return service.UpdateAllWhereContext(context.Background(), record, match)
+}
+
+/*
+This is UpdateAllWhere, with a context that can cancel the operation
+*/
+func (service RecordService) UpdateAllWhereContext(ctx context.Context, record interface{}, match matcher.Matcher) error {
+	record, fields, err := unpatch(record)
+	if err != nil {
+		return err
+	}
+	return service.updateWhere(ctx, record, fields, match)
 }
 
 /*
-This creates a record into the service
+This creates a record into the service.  The key generated for an autoincremented field is written into the record, so such a record must be passed as a pointer
 */
 func (service RecordService) Create(record interface{}) error {
+	return service.CreateContext(context.Background(), record)
+}
+
+/*
+This is Create, with a context that can cancel the operation
+*/
+func (service RecordService) CreateContext(ctx context.Context, record interface{}) error {
+	if err := checkPointer("Create", record); err != nil {
+		return err
+	}
+	if err := service.checkRecords(sliceOf(record), nil); err != nil {
+		return err
+	}
+	return service.delegate.createAll(ctx, sliceOf(record))
+}
+
+/*
+This wraps a single record in a slice, for the services that work on many
+*/
+func sliceOf(record interface{}) interface{} {
 	sliceType := reflect.SliceOf(reflect.TypeOf(record))
 	slice := reflect.MakeSlice(sliceType, 0, 1)
 	slice = reflect.Append(slice, reflect.ValueOf(record))
-	return service.delegate.createAll(slice.Interface())
+	return slice.Interface()
 }
 
 /*
-This creates many records with one call
+This creates many records with one call.  The keys generated for autoincremented fields are written back into the records, so they must be in a slice of structs or a slice of pointers.  Sql services split large slices into several statements, in a transaction
 */
 func (service RecordService) CreateAll(record interface{}) error {
-	return service.delegate.createAll(record)
+	return service.CreateAllContext(context.Background(), record)
+}
+
+/*
+This is CreateAll, with a context that can cancel the operation
+*/
+func (service RecordService) CreateAllContext(ctx context.Context, record interface{}) error {
+	_, val := typeAndVal(record)
+	if err := checkWritable("CreateAll", val); err != nil {
+		return err
+	}
+	if err := service.checkRecords(record, nil); err != nil {
+		return err
+	}
+	return service.delegate.createAll(ctx, record)
+}
+
+/*
+This creates the record, or updates the stored record with the same primary key.  A record whose autoincremented primary key is zero is always created, and its key is written back, so such a record must be passed as a pointer
+*/
+func (service RecordService) Upsert(record interface{}) error {
+	return service.UpsertContext(context.Background(), record)
+}
+
+/*
+This is Upsert, with a context that can cancel the operation
+*/
+func (service RecordService) UpsertContext(ctx context.Context, record interface{}) error {
+	if err := checkPointer("Upsert", record); err != nil {
+		return err
+	}
+	return service.UpsertAllByContext(ctx, "", sliceOf(record))
+}
+
+/*
+This is Upsert for many records, keyed on the primary key
+*/
+func (service RecordService) UpsertAll(records interface{}) error {
+	return service.UpsertAllByContext(context.Background(), "", records)
+}
+
+/*
+This is UpsertAll, with a context that can cancel the operation
+*/
+func (service RecordService) UpsertAllContext(ctx context.Context, records interface{}) error {
+	return service.UpsertAllByContext(ctx, "", records)
+}
+
+/*
+This creates the record, or updates the stored record that has the same value in the key field.  The key must be the primary key or a unique field
+*/
+func (service RecordService) UpsertBy(key string, record interface{}) error {
+	if err := checkPointer("Upsert", record); err != nil {
+		return err
+	}
+	return service.UpsertAllByContext(context.Background(), key, sliceOf(record))
+}
+
+/*
+This is UpsertBy for many records
+*/
+func (service RecordService) UpsertAllBy(key string, records interface{}) error {
+	return service.UpsertAllByContext(context.Background(), key, records)
+}
+
+/*
+This is UpsertAllBy, with a context that can cancel the operation.  An empty key means the primary key.
+
+An updated record keeps its primary key and its immutable fields.  When the key is not the primary key, the primary key of every record is read back afterwards, so the records end up with the keys they are stored under.  MySQL updates a row on a clash with any unique field, not only the key, and PostgreSQL does not move the sequence of a bigserial key past the values given to it
+*/
+func (service RecordService) UpsertAllByContext(ctx context.Context, key string, records interface{}) error {
+	_, val := typeAndVal(records)
+	if val.Len() == 0 {
+		return nil
+	}
+	if err := checkWritable("Upsert", val); err != nil {
+		return err
+	}
+	if err := service.checkRecords(records, nil); err != nil {
+		return err
+	}
+	first := val.Index(0).Interface()
+	keyField, err := upsertKey(first, key)
+	if err != nil {
+		return err
+	}
+	generated, _ := autoincrementField(goflect.GetInfo(first))
+
+	if keyField.IsAutoincrement {
+		//Records without a key yet are created, so that the database generates one
+		keyed, fresh := splitGenerated(val, keyField)
+		switch {
+		case keyed.Len() == 0:
+			return service.delegate.createAll(ctx, fresh.Interface())
+		case fresh.Len() > 0:
+			return service.TransactionContext(ctx, func(tx RecordService) error {
+				err := tx.delegate.createAll(ctx, fresh.Interface())
+				if err != nil {
+					return err
+				}
+				return tx.delegate.upsertAll(ctx, keyed.Interface(), keyField.Name)
+			})
+		}
+		return service.delegate.upsertAll(ctx, keyed.Interface(), keyField.Name)
+	}
+
+	err = service.delegate.upsertAll(ctx, records, keyField.Name)
+	if err != nil || generated.Name == "" {
+		return err
+	}
+	return service.readKeys(ctx, val, keyField, generated)
+}
+
+/*
+This finds the field an upsert is keyed on
+*/
+func upsertKey(record interface{}, key string) (goflect.Info, error) {
+	for _, field := range goflect.GetInfo(record) {
+		if key == "" && field.IsPrimary {
+			return field, nil
+		}
+		if key != "" && field.Name == key {
+			if !field.IsPrimary && !field.IsUnique {
+				return field, RecordError("Upsert needs a primary key or unique field, but " + key + " is neither")
+			}
+			return field, nil
+		}
+	}
+	if key == "" {
+		return goflect.Info{}, RecordError("No primary key found")
+	}
+	return goflect.Info{}, RecordError("Unknown upsert field: " + key)
+}
+
+/*
+This separates the records that have a generated key already from those that do not.  Both are returned as slices of pointers, to the caller's records where they can be reached
+*/
+func splitGenerated(val reflect.Value, field goflect.Info) (keyed, fresh reflect.Value) {
+	typ := reflect.PtrTo(rowAt(val, 0).Type())
+	keyed = reflect.MakeSlice(reflect.SliceOf(typ), 0, val.Len())
+	fresh = reflect.MakeSlice(reflect.SliceOf(typ), 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		row := rowAt(val, i)
+		var pointer reflect.Value
+		if row.CanAddr() {
+			pointer = row.Addr()
+		} else {
+			pointer = reflect.New(row.Type())
+			pointer.Elem().Set(row)
+		}
+		if row.FieldByName(field.Name).IsZero() {
+			fresh = reflect.Append(fresh, pointer)
+		} else {
+			keyed = reflect.Append(keyed, pointer)
+		}
+	}
+	return keyed, fresh
+}
+
+/*
+This looks up the generated keys of upserted records by their key field, and writes them into the records
+*/
+func (service RecordService) readKeys(ctx context.Context, val reflect.Value, key, generated goflect.Info) error {
+	//The values are looked up a few hundred at a time, to stay under the limit on bound values
+	const chunk = 500
+	typ := rowAt(val, 0).Type()
+	keyType, _ := typ.FieldByName(key.Name)
+	for start := 0; start < val.Len(); start += chunk {
+		end := start + chunk
+		if end > val.Len() {
+			end = val.Len()
+		}
+		values := reflect.MakeSlice(reflect.SliceOf(keyType.Type), 0, end-start)
+		for i := start; i < end; i++ {
+			values = reflect.Append(values, rowAt(val, i).FieldByName(key.Name))
+		}
+		match := matcher.NewStructMatcher()
+		match.AddField(key.Name, matcher.In(values.Interface()))
+		stored := reflect.New(typ)
+		cursor, err := service.ReadAllWhereContext(ctx, stored.Interface(), match)
+		if err != nil {
+			return err
+		}
+		ids := make(map[interface{}]int64)
+		for cursor.Next() {
+			if err = cursor.Scan(stored.Interface()); err != nil {
+				cursor.Close()
+				return err
+			}
+			ids[stored.Elem().FieldByName(key.Name).Interface()] = integerOf(stored.Elem().FieldByName(generated.Name))
+		}
+		if err = cursor.Err(); err != nil {
+			return err
+		}
+		for i := start; i < end; i++ {
+			row := rowAt(val, i)
+			if id, present := ids[row.FieldByName(key.Name).Interface()]; present {
+				setKey(row, generated, id)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+This returns a copy of the service that writes at most size records per statement, for services that batch their writes.  Other services are returned as they are.  Zero lets the service pick the largest batch it can
+*/
+func (service RecordService) WithBatchSize(size int) RecordService {
+	if batched, ok := service.delegate.(batchedService); ok {
+		return RecordService{delegate: batched.withBatchSize(size), validate: service.validate}
+	}
+	return service
 }
 
 func (service RecordService) Get(id int64, record interface{}) error {
@@ -98,17 +646,36 @@ func (service RecordService) Get(id int64, record interface{}) error {
 }
 
 func (service RecordService) Read(record interface{}) error {
+	return service.ReadContext(context.Background(), record)
+}
+
+/*
+This is Read, with a context that can cancel the operation
+*/
+func (service RecordService) ReadContext(ctx context.Context, record interface{}) error {
 	match, err := primaryMatcher(record)
 	if err != nil {
 		return err
 	}
-	next, err := service.ReadAllWhere(record, match)
+	return service.readInto(ctx, record, match)
+}
+
+/*
+This scans the records that match into the record.  If there are several, the last one wins
+*/
+func (service RecordService) readInto(ctx context.Context, record interface{}, match matcher.Matcher) error {
+	cursor, err := service.ReadAllWhereContext(ctx, record, match)
 	if err != nil {
 		return err
 	}
-	for next(record) {
-	} //The last call closes the result set, important!
-	return nil
+	defer cursor.Close()
+	for cursor.Next() {
+		err = cursor.Scan(record)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
 }
 
 func (service RecordService) ReadById(id int64, record interface{}) error {
@@ -119,13 +686,7 @@ func (service RecordService) ReadById(id int64, record interface{}) error {
 			match.AddField(field.Name, matcher.Eq(id))
 		}
 	}
-	next, err := service.ReadAllWhere(record, match)
-	if err != nil {
-		return err
-	}
-	for next(record) {
-	} //The last call closes the result set, important!
-	return nil
+	return service.readInto(context.Background(), record, match)
 }
 
 func (service RecordService) DeleteById(id int64, record interface{}) error {
@@ -136,21 +697,79 @@ func (service RecordService) DeleteById(id int64, record interface{}) error {
 			match.AddField(field.Name, matcher.Eq(id))
 		}
 	}
-	return service.delegate.deleteAll(record, match)
+	return service.DeleteAllWhere(record, match)
 }
 
 /*
-This function can be used to return a set of records that match a set of criteria.  It accepts a matcher that describes a record set.
+This function can be used to return a set of records that match a set of criteria.  It accepts a matcher that describes a record set, and optionally a QueryOptions to order and page through the results.  Use Iterate to read the cursor with a closure
 */
-func (service RecordService) ReadAllWhere(record interface{}, match matcher.Matcher) (func(record ...interface{}) bool, error) {
-	return service.delegate.readAll(match, record)
+func (service RecordService) ReadAllWhere(record interface{}, match matcher.Matcher, options ...QueryOptions) (*Cursor, error) {
+	return service.ReadAllWhereContext(context.Background(), record, match, options...)
 }
 
 /*
-This returns all of the records that the service has access to
+This is ReadAllWhere, with a context.  Once the context is done, the query is cancelled, Next returns false and Err returns the context's error
 */
-func (service RecordService) ReadAll(record interface{}) (func(record ...interface{}) bool, error) {
-	return service.ReadAllWhere(record, matcher.Any())
+func (service RecordService) ReadAllWhereContext(ctx context.Context, record interface{}, match matcher.Matcher, options ...QueryOptions) (*Cursor, error) {
+	option, err := singleOption(options)
+	if err != nil {
+		return nil, err
+	}
+	return service.delegate.readAll(ctx, match, option, record)
+}
+
+/*
+This returns all of the records that the service has access to, optionally ordered and paged by a QueryOptions
+*/
+func (service RecordService) ReadAll(record interface{}, options ...QueryOptions) (*Cursor, error) {
+	return service.ReadAllWhere(record, matcher.Any(), options...)
+}
+
+func singleOption(options []QueryOptions) (QueryOptions, error) {
+	switch len(options) {
+	case 0:
+		return QueryOptions{}, nil
+	case 1:
+		return options[0], nil
+	}
+	return QueryOptions{}, RecordError("Only one QueryOptions may be provided")
+}
+
+/*
+This checks the options against the fields of the record, and returns the primary key when a keyset cursor is requested.  Fields the sql tag ignores have no column, so they cannot be ordered by
+*/
+func (options QueryOptions) validate(record interface{}) (primary goflect.Info, err error) {
+	fields := sqliteFields(record)
+	known := make(map[string]bool)
+	for _, field := range fields {
+		known[field.Name] = true
+		if field.IsPrimary {
+			primary = field
+		}
+	}
+	for _, order := range options.OrderBy {
+		if !known[order.Field] {
+			return primary, RecordError("Unknown order field: " + order.Field)
+		}
+	}
+	if options.Limit < 0 || options.Offset < 0 {
+		return primary, RecordError("Limit and Offset cannot be negative")
+	}
+	if options.After != nil && primary.Name == "" {
+		return primary, RecordError("No primary key found")
+	}
+	//The cursor only holds the primary key, so it cannot say where a page ended in any other order
+	if options.After != nil && (len(options.OrderBy) > 1 || (len(options.OrderBy) == 1 && options.OrderBy[0].Field != primary.Name)) {
+		return primary, RecordError("After can only be used when ordering by the primary key " + primary.Name)
+	}
+	return primary, nil
+}
+
+/*
+This determines if the keyset cursor walks the primary key in descending order
+*/
+func (options QueryOptions) afterDescending() bool {
+	return len(options.OrderBy) == 1 && options.OrderBy[0].Descending
 }
 
 /***
@@ -164,11 +783,39 @@ func NewDummyService() RecordService {
 	return RecordService{delegate: new(dummyService)}
 }
 
+/*
+This creates a new in memory service.  It behaves like a database, without needing one, and is intended for unit tests and as a reference for other services
+*/
+func NewMemoryService() RecordService {
+	return RecordService{delegate: new(memoryService)}
+}
+
 /*
 This function takes a connection to a sqlite service, and returns a Record Service.  Should only be used with application setup code
 */
 func NewSqliteService(conn *sql.DB) RecordService {
-	return RecordService{delegate: sqliteRecordService{Conn: conn}}
+	return NewSqlService(conn, SqliteDialect())
+}
+
+/*
+This function takes a connection to a MySQL database, and returns a Record Service.  Migrations are not supported
+*/
+func NewMysqlService(conn *sql.DB) RecordService {
+	return NewSqlService(conn, MysqlDialect())
+}
+
+/*
+This function takes a connection to a PostgreSQL database, and returns a Record Service.  Migrations are not supported
+*/
+func NewPostgresService(conn *sql.DB) RecordService {
+	return NewSqlService(conn, PostgresDialect())
+}
+
+/*
+This function takes a connection, and the dialect of sql it speaks, and returns a Record Service.  The driver for the database must be imported by the application
+*/
+func NewSqlService(conn *sql.DB, dialect Dialect) RecordService {
+	return RecordService{delegate: newSqlService(conn, dialect)}
 }
 
 /*
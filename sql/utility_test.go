@@ -36,3 +36,132 @@ func ExampleRecordService_Update_primaryKeyRequired() {
 	//Output:
 	//No primary key found
 }
+
+/*
+The dummy service checks the options, and keeps the last ones it was given
+*/
+func ExampleRecordService_ReadAllWhere_dummyOptions() {
+	type Foo struct {
+		Id int64 `sql:"primary"`
+		A  string
+	}
+	dummy := new(dummyService)
+	service := RecordService{delegate: dummy}
+
+	_, err := service.ReadAll(&Foo{}, QueryOptions{OrderBy: []Order{Desc("A")}, Limit: 10})
+	fmt.Println(err, dummy.Options)
+
+	_, err = service.ReadAll(&Foo{}, QueryOptions{OrderBy: []Order{Asc("B")}})
+	fmt.Println(err)
+
+	//Output:
+	//<nil> {[{A true}] 10 0 <nil>}
+	//Unknown order field: B
+}
+
+/*
+The callback decides the outcome of a transaction.  Services that cannot start one return the error without calling it
+*/
+func ExampleRecordService_Transaction() {
+	type Foo struct {
+		Id int64 `sql:"primary"`
+		A  string
+	}
+	err := NewDummyService().Transaction(func(tx RecordService) error {
+		return tx.Create(&Foo{Id: 1, A: "bacon"})
+	})
+	fmt.Println(err)
+
+	err = NewBuggyService().Transaction(func(tx RecordService) error {
+		fmt.Println("Not called")
+		return nil
+	})
+	fmt.Println(err)
+
+	//Output:
+	//<nil>
+	//Intentional Transaction Error
+}
+
+/*
+Upserting by a unique field updates the records that are already stored, creates the rest, and fills in the primary keys of both
+*/
+func ExampleRecordService_UpsertAllBy() {
+	type Host struct {
+		Id   int64  `sql:"primary,autoincrement"`
+		Name string `sql:"unique"`
+		Up   bool
+	}
+	service := NewMemoryService()
+	service.Define(&Host{})
+	service.Create(&Host{Name: "alpha"})
+
+	hosts := []Host{{Name: "beta", Up: true}, {Name: "alpha", Up: true}}
+	err := service.UpsertAllBy("Name", hosts)
+	fmt.Println(hosts, err)
+
+	err = service.UpsertAllBy("Up", hosts)
+	fmt.Println(err)
+
+	//Output:
+	//[{2 beta true} {1 alpha true}] <nil>
+	//Upsert needs a primary key or unique field, but Up is neither
+}
+
+/*
+The generated key is written into the record, so a record with an autoincremented field must be passed as a pointer
+*/
+func ExampleRecordService_Create_pointerRequired() {
+	type Foo struct {
+		Id int64 `sql:"primary,autoincrement"`
+		A  string
+	}
+	type Bar struct {
+		Name string `sql:"primary"`
+	}
+	service := NewMemoryService()
+	service.Define(&Foo{})
+	service.Define(&Bar{})
+
+	foo := Foo{A: "bacon"}
+	fmt.Println(service.Create(foo), foo.Id)
+	fmt.Println(service.Create(&foo), foo.Id)
+	foos := []*Foo{{A: "eggs"}, {A: "toast"}}
+	fmt.Println(service.CreateAll(foos), foos[0].Id, foos[1].Id)
+	fmt.Println(service.CreateAll([]interface{}{Foo{A: "jam"}}))
+	fmt.Println(service.Create(Bar{Name: "No key to write"}))
+
+	//Output:
+	//Create needs a pointer to Foo, so that the generated Id can be written back 0
+	//<nil> 1
+	//<nil> 2 3
+	//CreateAll needs a pointer to Foo, so that the generated Id can be written back
+	//<nil>
+}
+
+/*
+Fields that are written once are left alone by Update, and naming one in UpdateFields is an ImmutableFieldError
+*/
+func ExampleRecordService_UpdateFields() {
+	type Account struct {
+		Id      int64  `sql:"primary,autoincrement"`
+		Owner   string `sql:"immutable"`
+		Balance int64
+	}
+	service := NewMemoryService()
+	service.Define(&Account{})
+	account := Account{Owner: "alice", Balance: 10}
+	service.Create(&account)
+
+	fmt.Println(service.Update(&Account{Id: 1, Owner: "mallory", Balance: 20}))
+	fmt.Println(service.UpdateFields(&Account{Id: 1, Owner: "mallory"}, "Owner"))
+	fmt.Println(service.UpdateFields(&Account{Id: 1, Balance: 30}, "Balance"))
+	service.Get(1, &account)
+	fmt.Println(account)
+
+	//Output:
+	//<nil>
+	//Cannot change immutable field Account.Owner
+	//<nil>
+	//{1 alice 30}
+}
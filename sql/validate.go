@@ -0,0 +1,141 @@
+package records
+
+import (
+	"git.sevone.com/sdevlin/goflect.git/goflect"
+	"git.sevone.com/sdevlin/goflect.git/matcher"
+	"reflect"
+	"sync"
+)
+
+/*
+This is returned when a record fails the valid tag of one of its fields.  The expression is the parsed tag, as printed by the default printer, and the failures are the clauses of it that did not hold
+*/
+type InvalidRecordError struct {
+	Type       string
+	Field      string
+	Expression string
+	Failures   []matcher.Failure
+}
+
+func (e InvalidRecordError) Error() string {
+	return "Invalid " + e.Type + "." + e.Field + ", expected " + e.Expression
+}
+
+/*
+This is the valid tag of one field.  The tag is kept rather than its matcher, since a struct matcher holds the record it is matching, and cannot be shared by goroutines
+*/
+type fieldCheck struct {
+	field      string
+	tag        string
+	expression string
+}
+
+type typeChecks struct {
+	parser matcher.Parser
+	checks []fieldCheck
+	err    error
+}
+
+//The valid tags of each type are checked once, and kept here
+var validators sync.Map
+
+/*
+This returns the checks for the valid tags of the record type.  A tag that does not parse is returned as an error every time
+*/
+func checksFor(record interface{}) (typeChecks, error) {
+	typ, _ := typeAndVal(record)
+	if cached, present := validators.Load(typ); present {
+		found := cached.(typeChecks)
+		return found, found.err
+	}
+	found := typeChecks{}
+	parser, err := goflect.DefaultParser(record)
+	if err != nil {
+		found.err = err
+	}
+	found.parser = parser
+	for _, field := range goflect.GetInfo(record) {
+		if found.err != nil {
+			break
+		}
+		if field.ValidExpr == "" {
+			continue
+		}
+		match, err := parser.Parse(field.ValidExpr)
+		if err != nil {
+			found.err = RecordError("Cannot parse the valid tag of " + typ.Name() + "." + field.Name + ": " + err.Error())
+			break
+		}
+		expression, err := matcher.NewDefaultPrinter().Print(match)
+		if err != nil {
+			expression = field.ValidExpr
+		}
+		found.checks = append(found.checks, fieldCheck{field: field.Name, tag: field.ValidExpr, expression: expression})
+	}
+	validators.Store(typ, found)
+	return found, found.err
+}
+
+/*
+This runs each record through the valid tags of its type, and returns an InvalidRecordError for the first field that fails.  When fields are named, as they are for a partial update, only their tags are checked
+*/
+func validateRecords(records reflect.Value, fields []string) error {
+	if records.Len() == 0 {
+		return nil
+	}
+	found, err := checksFor(rowAt(records, 0).Interface())
+	if err != nil {
+		return err
+	}
+	named := make(map[string]bool)
+	for _, field := range fields {
+		named[field] = true
+	}
+	//Each call gets its own matchers, which are not shared with other goroutines
+	checks := make([]fieldCheck, 0, len(found.checks))
+	matches := make([]matcher.Matcher, 0, len(found.checks))
+	for _, check := range found.checks {
+		if len(fields) > 0 && !named[check.field] {
+			continue
+		}
+		match, err := found.parser.Parse(check.tag)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, check)
+		matches = append(matches, match)
+	}
+	for i := 0; i < records.Len(); i++ {
+		row := rowAt(records, i)
+		for j, check := range checks {
+			ok, err := matches[j].Match(row.Interface())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				failures, _ := matcher.Explain(matches[j], row.Interface())
+				return InvalidRecordError{Type: row.Type().Name(), Field: check.field, Expression: check.expression, Failures: failures}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+This returns a copy of the service that checks every record against the valid tags of its type before Create, Update or Upsert writes it.  Records that fail are rejected with an InvalidRecordError, and nothing is written
+*/
+func (service RecordService) WithValidation() RecordService {
+	service.validate = true
+	return service
+}
+
+/*
+This validates the records, if the service was asked to
+*/
+func (service RecordService) checkRecords(records interface{}, fields []string) error {
+	if !service.validate {
+		return nil
+	}
+	_, val := typeAndVal(records)
+	return validateRecords(val, fields)
+}
@@ -0,0 +1,97 @@
+package records
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+/*
+A service with validation rejects records that fail their valid tags, and names the field and the expression that failed
+*/
+func ExampleRecordService_WithValidation() {
+	type Port struct {
+		Id     int64  `sql:"primary,autoincrement"`
+		Name   string `valid:"Name != \"\""`
+		Number int64  `valid:"Number > 0 AND Number < 65536"`
+	}
+	service := NewMemoryService().WithValidation()
+	service.Define(&Port{})
+
+	fmt.Println(service.Create(&Port{Name: "ssh", Number: 22}))
+	fmt.Println(service.Create(&Port{Name: "huge", Number: 70000}))
+	fmt.Println(service.CreateAll([]Port{{Name: "http", Number: 80}, {Number: 443}}))
+	fmt.Println(service.Update(&Port{Id: 1, Name: "ssh", Number: -1}))
+	fmt.Println(service.Update(Only(&Port{Id: 1, Number: 2222}, "Number")))
+
+	err := service.Upsert(&Port{Id: 1, Name: "ssh", Number: 0})
+	if invalid, ok := err.(InvalidRecordError); ok {
+		fmt.Println(invalid.Field)
+		for _, failure := range invalid.Failures {
+			fmt.Println(failure)
+		}
+	}
+
+	//Output:
+	//<nil>
+	//Invalid Port.Number, expected Number > 0 AND Number < 65536
+	//Invalid Port.Name, expected Name != ""
+	//Invalid Port.Number, expected Number > 0 AND Number < 65536
+	//<nil>
+	//Number
+	//Number > 0, but Number is 0
+}
+
+func TestValidationOff(t *testing.T) {
+	type Port struct {
+		Id     int64 `sql:"primary,autoincrement"`
+		Number int64 `valid:"Number > 0"`
+	}
+	service := NewMemoryService()
+	service.Define(&Port{})
+	if err := service.Create(&Port{Number: -1}); err != nil {
+		t.Errorf("Services do not validate unless asked to, got %v", err)
+	}
+	err := service.WithValidation().Transaction(func(tx RecordService) error {
+		return tx.Create(&Port{Number: -1})
+	})
+	if _, ok := err.(InvalidRecordError); !ok {
+		t.Errorf("Transactions keep validation on, got %v", err)
+	}
+
+	type Broken struct {
+		Id int64 `sql:"primary" valid:"Id >"`
+	}
+	service.Define(&Broken{})
+	if err := service.WithValidation().Create(&Broken{Id: 1}); err == nil {
+		t.Error("Expected an error for a valid tag that does not parse")
+	}
+}
+
+func TestValidationConcurrent(t *testing.T) {
+	type Span struct {
+		Low  int64 `valid:"Low < High"`
+		High int64
+	}
+	done := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			var err error
+			for j := 0; j < 200 && err == nil; j++ {
+				low := int64(i*1000 + j)
+				if err = validateRecords(reflect.ValueOf([]Span{{Low: low, High: low + 1}}), nil); err != nil {
+					break
+				}
+				if validateRecords(reflect.ValueOf([]Span{{Low: low + 1, High: low}}), nil) == nil {
+					err = fmt.Errorf("Expected %v < %v to fail", low+1, low)
+				}
+			}
+			done <- err
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}